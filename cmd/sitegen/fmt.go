@@ -0,0 +1,174 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ehamiter/hithisisme/sitegen"
+)
+
+// fmtCmd formats .hi files like gofmt: with no paths it formats stdin to
+// stdout; directories are searched for .hi files.
+func fmtCmd(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs from canonical form")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	flags.Parse(args)
+
+	opts := fmtOptions{list: *list, diff: *diff, write: *write}
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = opts.format("<stdin>", src, nil)
+		}
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+	failed := false
+	for _, path := range flags.Args() {
+		if err := opts.formatPath(path); err != nil {
+			printError(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+type fmtOptions struct {
+	list, diff, write bool
+}
+
+func (o fmtOptions) formatPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return o.format(path, src, info)
+	}
+	var errs []error
+	walkErr := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(p) != ".hi" {
+			return nil
+		}
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := o.format(p, src, info); err != nil {
+			printError(err)
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d files could not be formatted", len(errs))
+	}
+	return nil
+}
+
+// format formats src, read from the file described by info or, if info is
+// nil, from stdin.
+func (o fmtOptions) format(name string, src []byte, info fs.FileInfo) error {
+	out, err := sitegen.Format(name, src)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(src, out) && (o.list || o.diff || o.write) {
+		return nil
+	}
+	if o.list {
+		fmt.Println(name)
+	}
+	if o.diff {
+		d, err := unifiedDiff(name, src, out)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(d)
+	}
+	if o.write && info != nil {
+		// keep the file's permissions
+		if err := os.WriteFile(name, out, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if !o.list && !o.diff && !o.write {
+		os.Stdout.Write(out)
+	}
+	return nil
+}
+
+// unifiedDiff runs the system diff over the original and formatted source,
+// as gofmt -d used to.
+func unifiedDiff(name string, a, b []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "hi-fmt")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	fa, fb := filepath.Join(dir, "orig"), filepath.Join(dir, "formatted")
+	if err := os.WriteFile(fa, a, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(fb, b, 0o644); err != nil {
+		return nil, err
+	}
+	slashed := filepath.ToSlash(name)
+	out, err := exec.Command("diff", "-u", "--label", "a/"+strings.TrimPrefix(slashed, "/"), "--label", "b/"+strings.TrimPrefix(slashed, "/"), fa, fb).Output()
+	if len(out) > 0 {
+		// diff exits 1 when the files differ
+		return out, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("computing diff: %v", err)
+	}
+	return nil, nil
+}
@@ -17,9 +17,12 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/ehamiter/hithisisme/sitegen"
 )
@@ -33,6 +36,10 @@ func main() {
 	switch cmd {
 	case "render":
 		renderCmd(os.Args[2:])
+	case "site":
+		siteCmd(os.Args[2:])
+	case "fmt":
+		fmtCmd(os.Args[2:])
 	default:
 		fmt.Fprintf(os.Stderr, "unknown subcommand %s\n", cmd)
 		os.Exit(1)
@@ -45,6 +52,9 @@ func renderCmd(args []string) {
 	out := fs.String("out", "public/index.html", "output HTML file")
 	dataDir := fs.String("data-dir", "data", "data directory")
 	layout := fs.String("layout", "templates/layout.html", "layout HTML file")
+	cards := fs.String("cards", "templates/cards", "directory of card partials")
+	vars := varFlags{}
+	fs.Var(vars, "var", "set ${key} in bindings to value, as key=value (repeatable)")
 	fs.Parse(args)
 
 	err := sitegen.Render(sitegen.RenderOptions{
@@ -52,9 +62,73 @@ func renderCmd(args []string) {
 		Out:     *out,
 		DataDir: *dataDir,
 		Layout:  *layout,
+		Cards:   *cards,
+		Vars:    vars,
 	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		printError(err)
+		os.Exit(1)
+	}
+}
+
+func siteCmd(args []string) {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	pages := fs.String("pages", "pages", "directory of .hi pages")
+	out := fs.String("out", "public", "output directory")
+	dataDir := fs.String("data-dir", "data", "data directory")
+	layout := fs.String("layout", "templates/layout.html", "layout HTML file for pages that don't set one")
+	cards := fs.String("cards", "templates/cards", "directory of card partials")
+	vars := varFlags{}
+	fs.Var(vars, "var", "set ${key} in bindings to value, as key=value (repeatable)")
+	fs.Parse(args)
+
+	err := sitegen.RenderSite(sitegen.RenderOptions{
+		Pages:   *pages,
+		Out:     *out,
+		DataDir: *dataDir,
+		Layout:  *layout,
+		Cards:   *cards,
+		Vars:    vars,
+	})
+	if err != nil {
+		printError(err)
 		os.Exit(1)
 	}
 }
+
+// varFlags collects repeated -var key=value flags.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	parts := make([]string, 0, len(v))
+	for k, val := range v {
+		parts = append(parts, k+"="+val)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func (v varFlags) Set(s string) error {
+	k, val, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	v[k] = val
+	return nil
+}
+
+// printError reports parse errors one per line in file:line:col form,
+// followed by the offending source line and a caret.
+func printError(err error) {
+	var list sitegen.ErrorList
+	if !errors.As(err, &list) {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	for _, e := range list {
+		fmt.Fprintln(os.Stderr, e)
+		if hint := e.Hint(); hint != "" {
+			fmt.Fprintln(os.Stderr, hint)
+		}
+	}
+}
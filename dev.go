@@ -24,6 +24,13 @@ import (
 	"os/exec"
 )
 
+// vars are the ${NAME} variables index.hi needs, with this site's values.
+// Setting one in the environment overrides it.
+var vars = []struct{ name, value string }{
+	{"GITHUB_USER", "ehamiter"},
+	{"ITUNES_ARTIST_ID", "1482332471"},
+}
+
 func main() {
 	// Parse command line flags
 	serve := flag.Bool("serve", false, "serve the site on http://localhost:8000 after building and rendering")
@@ -40,7 +47,15 @@ func main() {
 
 	// Render the site
 	log.Println("Rendering site...")
-	renderCmd := exec.Command("./hi", "render")
+	args := []string{"render"}
+	for _, v := range vars {
+		value := v.value
+		if env, ok := os.LookupEnv(v.name); ok {
+			value = env
+		}
+		args = append(args, "-var", v.name+"="+value)
+	}
+	renderCmd := exec.Command("./hi", args...)
 	renderCmd.Stdout = os.Stdout
 	renderCmd.Stderr = os.Stderr
 	if err := renderCmd.Run(); err != nil {
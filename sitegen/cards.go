@@ -0,0 +1,142 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cardFuncs are the functions card partials can call besides the
+// html/template builtins.
+var cardFuncs = template.FuncMap{
+	"str":      textString,
+	"truncate": func(n int, v interface{}) string { return truncateWords(fmt.Sprint(v), n) },
+	"slug":     func(v interface{}) string { return slugify(fmt.Sprint(v)) },
+	"date": func(v interface{}) interface{} {
+		if t, ok := parseDate(v); ok {
+			return t.Format("January 2, 2006")
+		}
+		return v
+	},
+}
+
+// loadCards parses each .html file in dir as a partial named after the
+// file, so templates/cards/app_card.html is app_card. A final newline in a
+// file is not part of the card. A missing dir holds no cards.
+func loadCards(dir string) (*template.Template, error) {
+	set := template.New("cards").Funcs(cardFuncs)
+	if dir == "" {
+		return set, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		if _, err := set.New(name).Parse(strings.TrimSuffix(string(data), "\n")); err != nil {
+			return nil, fmt.Errorf("card %s: %w", file, err)
+		}
+	}
+	return set, nil
+}
+
+// partialData copies v for a card partial with each HTML value, like the
+// body of a Markdown file, made a template.HTML, so html/template writes it
+// as markup instead of escaping it as text.
+func partialData(v interface{}) interface{} {
+	switch x := v.(type) {
+	case HTML:
+		return template.HTML(x)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = partialData(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i, e := range x {
+			a[i] = partialData(e)
+		}
+		return a
+	}
+	return v
+}
+
+// renderCards renders each item through the card partial name, in a grid of
+// cells. The partial sees the loop vars, loop and the item itself as item.
+// A partial can open the grid and its cells itself by defining
+// "<name>.grid" and "<name>.cell", each a single <div> the renderer closes;
+// the cell sees what the partial does. Directly inside a filter grid the
+// grid is already open, and each cell is tagged with the group for the
+// category filter.
+func (c *context) renderCards(name string, lc *LoopContext, buf *strings.Builder) error {
+	l := lc.Loop
+	card := c.cards.Lookup(name)
+	if card == nil {
+		return fmt.Errorf("%s: unknown presentation %s: no renderer registered and no %s.html in %s", l.Pos(), name, name, c.cardsDir)
+	}
+	grid, cell := c.cards.Lookup(name+".grid"), c.cards.Lookup(name+".cell")
+	group, grouped := lc.Group()
+	if !grouped {
+		buf.WriteString(`<section class="section">`)
+		buf.WriteString(`<div class="container">`)
+		if grid != nil {
+			if err := grid.Execute(buf, nil); err != nil {
+				return fmt.Errorf("%s: %w", l.Pos(), err)
+			}
+		} else {
+			buf.WriteString(`<div class="grid is-col-min-16">`)
+		}
+	}
+	for i, it := range lc.Items {
+		vars := lc.Vars(i)
+		vars["item"] = it
+		data := partialData(vars).(map[string]interface{})
+		switch {
+		case grouped:
+			if err := filterGridTmpl.ExecuteTemplate(buf, "cell", group); err != nil {
+				return err
+			}
+		case cell != nil:
+			if err := cell.Execute(buf, data); err != nil {
+				return fmt.Errorf("%s: %w", l.Pos(), err)
+			}
+		default:
+			buf.WriteString(`<div class="cell">`)
+		}
+		if err := card.Execute(buf, data); err != nil {
+			return fmt.Errorf("%s: %w", l.Pos(), err)
+		}
+		buf.WriteString(`</div>`)
+	}
+	if !grouped {
+		buf.WriteString(`</div>`)
+		buf.WriteString(`</div>`)
+		buf.WriteString(`</section>`)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func renderCardTest(t *testing.T, cards, src string, bindings map[string]interface{}) string {
+	t.Helper()
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := loadCards(cards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: bindings, cards: set, cardsDir: cards, stderr: &strings.Builder{}}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCards(t *testing.T) {
+	dir := t.TempDir()
+	card := `<p title="{{.item.name}}">{{.loop.index1}}/{{.loop.length}} {{.book.name}} by {{.author}}</p>` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "book_card.html"), []byte(card), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := `[for author, books in shelf]
+  [for book in books as book_card: name^]
+    book.name
+`
+	out := renderCardTest(t, dir, src, map[string]interface{}{
+		"shelf": map[string]interface{}{
+			"Le Guin": []interface{}{
+				map[string]interface{}{"name": `"Tehanu"`},
+				map[string]interface{}{"name": "A Wizard <of> Earthsea"},
+			},
+		},
+	})
+	for _, want := range []string{
+		`<div class="cell"><p title="A Wizard &lt;of&gt; Earthsea">2/2 A Wizard &lt;of&gt; Earthsea by Le Guin</p></div>`,
+		`<div class="cell"><p title="&#34;Tehanu&#34;">1/2 &#34;Tehanu&#34; by Le Guin</p></div>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCardGridAndCell(t *testing.T) {
+	dir := t.TempDir()
+	card := `{{define "pen_card.grid"}}<div class="grid" id="pens">{{end -}}
+{{define "pen_card.cell"}}<div class="cell {{.item.color}}">{{end -}}
+<b>{{.item.name}}</b>
+`
+	if err := os.WriteFile(filepath.Join(dir, "pen_card.html"), []byte(card), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := renderCardTest(t, dir, "[for pen in pens as pen_card]\n", map[string]interface{}{
+		"pens": []interface{}{map[string]interface{}{"name": "Jotter", "color": "blue"}},
+	})
+	want := `<section class="section"><div class="container"><div class="grid" id="pens"><div class="cell blue"><b>Jotter</b></div></div></div></section>`
+	if out != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+}
+
+func TestCardMarkdownBody(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"cards/note_card.html": "<h3>{{.item.title}}</h3>{{.item.body}}\n",
+		"notes/a.md":           "---\ntitle: <A>\n---\nSome *notes*.\n",
+	})
+	notes, err := loadGlob(dir, "notes/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := renderCardTest(t, filepath.Join(dir, "cards"), "[for note in notes as note_card]\n", map[string]interface{}{"notes": notes})
+	if want := "<h3>&lt;A&gt;</h3><p>Some <em>notes</em>.</p>\n"; !strings.Contains(out, want) {
+		t.Errorf("output is missing %q:\n%s", want, out)
+	}
+}
+
+func TestDefaultCards(t *testing.T) {
+	src := `[for repo in repos]
+  repo.name
+`
+	out := renderCardTest(t, "../templates/cards", src, map[string]interface{}{
+		"repos": []interface{}{map[string]interface{}{
+			"name":             "Bob's <tool>",
+			"html_url":         "https://example.com/?a=1&b=2",
+			"description":      nil,
+			"stargazers_count": 1234.0,
+			"updated_at":       "2025-01-04T10:00:00Z",
+			"language":         "Go",
+		}},
+	})
+	for _, want := range []string{
+		`<a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="noopener noreferrer">Bob&#39;s &lt;tool&gt;</a>`,
+		`<p></p>`,
+		`<span>1234</span>`,
+		`<span class="tag is-info is-light">Go</span>`,
+		"Last updated January 4, 2025\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestUnknownCard(t *testing.T) {
+	_, nodes, err := Parse(strings.NewReader("[for x in xs as nope]\n  x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, _ := loadCards("")
+	c := &context{bindings: map[string]interface{}{"xs": []interface{}{"a"}}, cards: set, cardsDir: "templates/cards"}
+	err = c.renderNodes(nodes, map[string]interface{}{}, &strings.Builder{})
+	if err == nil || err.Error() != "<input>:1:1: unknown presentation nope: no renderer registered and no nope.html in templates/cards" {
+		t.Fatalf("got %v", err)
+	}
+}
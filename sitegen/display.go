@@ -0,0 +1,133 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// fieldHTML renders the value of a field line by its type:
+//
+//   - nil renders nothing
+//   - HTML is written as-is in a <div>
+//   - arrays become a list of tags
+//   - http and https URLs become links
+//   - dates are formatted as "January 2, 2006", unless the field already
+//     went through date or relative
+//   - whole numbers get thousands separators
+//
+// Anything else, and Text from the text filter, is written escaped in a <p>.
+// A filter on the field line overrides the default: join for arrays, date
+// for dates, text for all of them, so `| text` writes a year or an ID
+// without separators.
+func fieldHTML(v interface{}, fs []Filter) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case HTML:
+		return fmt.Sprintf("<div>%s</div>", x)
+	case Text:
+		return fmt.Sprintf("<p>%s</p>", htmlEscape(string(x)))
+	case []interface{}:
+		var b strings.Builder
+		for _, item := range x {
+			if item == nil {
+				continue
+			}
+			fmt.Fprintf(&b, `<span class="tag">%s</span>`, htmlEscape(textString(item)))
+		}
+		if b.Len() == 0 {
+			return ""
+		}
+		return `<div class="tags">` + b.String() + `</div>`
+	case string:
+		if isWebURL(x) {
+			return fmt.Sprintf(`<p><a href="%s" rel="noopener noreferrer">%s</a></p>`, htmlEscape(x), htmlEscape(x))
+		}
+		if t, ok := parseDate(x); ok && !hasFilter(fs, "date", "relative") {
+			return fmt.Sprintf("<p>%s</p>", t.Format("January 2, 2006"))
+		}
+	case float64:
+		if s, ok := groupThousands(x); ok {
+			return fmt.Sprintf("<p>%s</p>", s)
+		}
+	case int:
+		s, _ := groupThousands(float64(x))
+		return fmt.Sprintf("<p>%s</p>", s)
+	}
+	return fmt.Sprintf("<p>%s</p>", htmlEscape(textString(v)))
+}
+
+// textString returns v as plain text: nil is empty and numbers are written
+// without exponents.
+func textString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// isWebURL reports whether s is an absolute http or https URL, the only
+// kind fieldHTML links to.
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "http" || scheme == "https"
+}
+
+// groupThousands formats a whole number with commas between each group of
+// three digits. It reports false for fractions and numbers too large to be
+// exact.
+func groupThousands(f float64) (string, bool) {
+	if f != math.Trunc(f) || math.Abs(f) >= 1<<53 {
+		return "", false
+	}
+	digits := strconv.FormatFloat(math.Abs(f), 'f', 0, 64)
+	var b strings.Builder
+	if f < 0 {
+		b.WriteByte('-')
+	}
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String(), true
+}
+
+// hasFilter reports whether fs uses any of the named filters.
+func hasFilter(fs []Filter, names ...string) bool {
+	for _, f := range fs {
+		for _, name := range names {
+			if f.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldHTML(t *testing.T) {
+	tests := []struct {
+		line string
+		v    interface{}
+		want string
+	}{
+		{"app.price", nil, ""},
+		{"app.genres", []interface{}{"Games", "<Puzzle>", nil}, `<div class="tags"><span class="tag">Games</span><span class="tag">&lt;Puzzle&gt;</span></div>`},
+		{"app.genres", []interface{}{}, ""},
+		{`app.genres | join ", "`, []interface{}{"Games", "Puzzle"}, "<p>Games, Puzzle</p>"},
+		{"repo.html_url", `https://example.com/?a=1&b="2"`, `<p><a href="https://example.com/?a=1&amp;b=&quot;2&quot;" rel="noopener noreferrer">https://example.com/?a=1&amp;b=&quot;2&quot;</a></p>`},
+		{"repo.html_url", "javascript:alert(1)", "<p>javascript:alert(1)</p>"},
+		{"repo.html_url", "//example.com/x", "<p>//example.com/x</p>"},
+		{"repo.html_url | text", "https://example.com", "<p>https://example.com</p>"},
+		{"repo.updated_at", "2025-03-01T12:00:00Z", "<p>March 1, 2025</p>"},
+		{"thing.date_published", "2025-09-01", "<p>September 1, 2025</p>"},
+		{`thing.date_published | date "2006-01-02"`, "2025-09-01T08:00:00Z", "<p>2025-09-01</p>"},
+		{"thing.date_published | text", "2025-09-01", "<p>2025-09-01</p>"},
+		{"repo.stargazers_count", 1234567.0, "<p>1,234,567</p>"},
+		{"repo.stargazers_count", -1000.0, "<p>-1,000</p>"},
+		{"repo.stargazers_count", 999.0, "<p>999</p>"},
+		{"app.averageUserRating", 4.5, "<p>4.5</p>"},
+		{"repo.stargazers_count | text", 1234.0, "<p>1234</p>"},
+		{"note.year | text", 2024.0, "<p>2024</p>"},
+		{"app.isFree", true, "<p>true</p>"},
+		{"thing.description | markdown", "*hi*", "<div><p><em>hi</em></p>\n</div>"},
+	}
+	for _, tt := range tests {
+		f := parseField(t, tt.line)
+		v, err := applyFilters(tt.v, f.Filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.line, err)
+		}
+		if got := fieldHTML(v, f.Filters); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestNullFieldIsNotMissing(t *testing.T) {
+	_, nodes, err := Parse(strings.NewReader("app.price\napp.rating\n<p>{app.price}{app.seller}</p>\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr strings.Builder
+	c := &context{bindings: map[string]interface{}{"app": map[string]interface{}{"price": nil}}, stderr: &stderr}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p></p>\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	want := "<input>:2:1: warning: missing field app.rating\n" +
+		"<input>:3:1: warning: missing field app.seller\n"
+	if stderr.String() != want {
+		t.Errorf("got warnings\n%s\nwant\n%s", stderr.String(), want)
+	}
+}
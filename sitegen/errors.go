@@ -0,0 +1,97 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseError is a problem found at a specific location in a .hi file.
+type ParseError struct {
+	Pos
+	Msg    string
+	Source string // the offending source line
+}
+
+// Error formats the error compiler-style as file:line:col: message.
+func (e *ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Hint returns the offending source line with a caret under the column.
+// Col counts bytes, so the caret is indented by a space for each character
+// before it, however many bytes that character takes.
+func (e *ParseError) Hint() string {
+	if e.Source == "" {
+		return ""
+	}
+	var caret strings.Builder
+	for i, r := range e.Source {
+		if i >= e.Col-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+	return e.Source + "\n" + caret.String()
+}
+
+// ErrorList collects every ParseError found in a single pass. Err sorts it
+// into source order.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns the list as an error, or nil if it is empty. The errors are
+// sorted by position; the files they are in keep the order they were first
+// reported in, so errors in an include stay where it was found.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	files := make(map[string]int)
+	for _, e := range l {
+		if _, ok := files[e.File]; !ok {
+			files[e.File] = len(files)
+		}
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Pos, l[j].Pos
+		if a.File != b.File {
+			return files[a.File] < files[b.File]
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return l
+}
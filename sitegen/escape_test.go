@@ -0,0 +1,179 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hostileThings are things.json entries whose titles, categories and URLs
+// try to break out of the markup they land in.
+var hostileThings = []interface{}{
+	map[string]interface{}{
+		"title":          `Bob's "best" </a><script>alert(1)</script>`,
+		"url":            "javascript:alert(1)",
+		"description":    `<img src=x onerror=alert(1)>`,
+		"category":       `x');alert(1);('`,
+		"date_published": "2025-01-02",
+	},
+	map[string]interface{}{
+		"title":          `Gloves`,
+		"url":            "https://example.com/?a=1&b=2",
+		"description":    "Warm",
+		"category":       `"><img src=x onerror=alert(1)>`,
+		"date_published": "2025-01-01",
+	},
+}
+
+func TestEscapeHostileThings(t *testing.T) {
+	src := `[for category, items in things group by category as filter_grid: category^]
+  [for thing in items as thing_card: title^]
+`
+	out := renderCardTest(t, "../templates/cards", src, map[string]interface{}{"things": hostileThings})
+	for _, bad := range []string{"<script>", "<img", "javascript:", "');alert(1)"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("output contains %q:\n%s", bad, out)
+		}
+	}
+	for _, want := range []string{
+		// filter buttons: JS string, then attribute
+		`onclick="filterThings('x\u0027);alert(1);(\u0027')">X&#39;);alert(1);(&#39;</button>`,
+		`onclick="filterThings('\u0022\u003e\u003cimg src=x onerror=alert(1)\u003e')">&#34;&gt;&lt;img src=x onerror=alert(1)&gt;</button>`,
+		// grid cells
+		`data-category="x&#39;);alert(1);(&#39;"`,
+		`data-category="&#34;&gt;&lt;img src=x onerror=alert(1)&gt;"`,
+		// thing card
+		`onclick="copyCardLink(event, 'x\u0027);alert(1);(\u0027', 'bobs_best_ascriptalert1script')"`,
+		`<a href="#ZgotmplZ" target="_blank" rel="noopener noreferrer">Bob&#39;s &#34;best&#34; &lt;/a&gt;&lt;script&gt;alert(1)&lt;/script&gt;</a>`,
+		`<p>&lt;img src=x onerror=alert(1)&gt;</p>`,
+		`<a href="https://example.com/?a=1&amp;b=2"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestEscapeRawLines(t *testing.T) {
+	src := `[for thing in things]
+  <a href="{thing.url}" onclick="pick('{thing.category}')" data-c="{thing.category}">{thing.title}</a>
+  <script>var t = {thing.title};</script>
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: map[string]interface{}{"things": hostileThings[:1]}}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `<a href="#ZgotmplZ" onclick="pick('x\u0027);alert(1);(\u0027')" data-c="x&#39;);alert(1);(&#39;">Bob&#39;s &#34;best&#34; &lt;/a&gt;&lt;script&gt;alert(1)&lt;/script&gt;</a>
+<script>var t = "Bob's \"best\" \u003c/a\u003e\u003cscript\u003ealert(1)\u003c/script\u003e";</script>
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	_, nodes, err = Parse(strings.NewReader("[for thing in things]\n  <a title=\"{thing.title}\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.renderNodes(nodes, map[string]interface{}{}, &buf)
+	if want := "<input>:2:3: raw HTML with placeholders must not end inside a tag, script or style, so the values can be escaped for it"; err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
+func TestEscapeThingPages(t *testing.T) {
+	// thing pages are rendered from templates/thing.html
+	wd, _ := os.Getwd()
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	out := t.TempDir()
+	things := append([]interface{}{map[string]interface{}{"title": "Escape", "category": "../outside"}}, hostileThings...)
+	var stderr strings.Builder
+	c := &context{bindings: map[string]interface{}{"things": things}, stderr: &stderr}
+	if err := c.generateThingPages(out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), `skipping the page for "Escape": category "../outside" is not a directory name`) {
+		t.Errorf("missing warning, got %q", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(out, "outside")); err == nil {
+		t.Error("wrote a page outside the things directory")
+	}
+	page, err := os.ReadFile(filepath.Join(out, "things", `x');alert(1);('`, "bobs_best_ascriptalert1script.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"<script>alert", "<img", `');alert`} {
+		if strings.Contains(string(page), bad) {
+			t.Errorf("page contains %q:\n%s", bad, page)
+		}
+	}
+	if want := `window.location.href = 'https://hithisisme.com/things/x\u0027);alert(1);(\u0027#bobs_best_ascriptalert1script';`; !strings.Contains(string(page), want) {
+		t.Errorf("page is missing %q:\n%s", want, page)
+	}
+}
+
+func TestThingPagesFromData(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	data, err := os.ReadFile("data/things.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var things []interface{}
+	if err := json.Unmarshal(data, &things); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	c := &context{bindings: map[string]interface{}{"things": things}, stderr: &strings.Builder{}}
+	if err := c.generateThingPages(out); err != nil {
+		t.Fatal(err)
+	}
+	// REI Co-op Swiftland 7" Running Shorts - Men's
+	page, err := os.ReadFile(filepath.Join(out, "things", "running", "rei_co-op_swiftland_7_running_shorts_-_mens.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<meta property="og:title" content="REI Co-op Swiftland 7&#34; Running Shorts - Men&#39;s">`,
+		`<h1>REI Co-op Swiftland 7&#34; Running Shorts - Men&#39;s</h1>`,
+		`window.location.href = 'https://hithisisme.com/things/running#rei_co-op_swiftland_7_running_shorts_-_mens';`,
+		// html/template drops comments written out in the template
+		"<!-- Open Graph / Facebook -->",
+		"<!-- Canonical URL -->",
+		"// Redirect to main page with hash\n",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page is missing %q:\n%s", want, page)
+		}
+	}
+}
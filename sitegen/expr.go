@@ -0,0 +1,369 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Expr is a condition expression, e.g. the test of an [if] block or a
+// loop's where clause.
+type Expr interface{}
+
+// PathExpr is a dotted field path resolved against the current vars.
+type PathExpr struct {
+	Path string
+}
+
+// LitExpr is a string, number, boolean or null literal.
+type LitExpr struct {
+	Value interface{}
+}
+
+// NotExpr negates the truthiness of X.
+type NotExpr struct {
+	X Expr
+}
+
+// ExistsExpr is true when X resolves to a non-null value.
+type ExistsExpr struct {
+	X Expr
+}
+
+// BinaryExpr applies one of ==, !=, <, >, in, contains, and or or to X and
+// Y.
+type BinaryExpr struct {
+	Op   string
+	X, Y Expr
+}
+
+// exprError is a syntax error at a byte offset within an expression or a
+// line of code.
+type exprError struct {
+	off int
+	msg string
+}
+
+func (e *exprError) Error() string { return e.msg }
+
+// exprPunct are the characters that are tokens of their own in expressions.
+const exprPunct = "()<>=!"
+
+// lexExprTokens lexes an expression or a list of arguments with lex, joining
+// = and ! with a following = into the == and != operators.
+func lexExprTokens(s string) ([]token, error) {
+	toks, err := lex(s, exprPunct)
+	if err != nil {
+		return nil, err
+	}
+	out := toks[:0]
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.is("=") || t.is("!") {
+			if n := toks[i+1]; !n.is("=") || n.off != t.off+1 {
+				return nil, &exprError{t.off, fmt.Sprintf("unexpected %q", t.text[0])}
+			}
+			t.text += "="
+			i++
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// isPath reports whether s is a dotted field path such as repo.name. After
+// the first segment a segment can be an array index, as in apps.0.trackName.
+func isPath(s string) bool {
+	for i, part := range strings.Split(s, ".") {
+		if !identRe.MatchString(part) && (i == 0 || !isIndex(part)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndex reports whether s is all digits.
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumber reports whether a word is meant as a number: it starts with a
+// digit or a minus sign.
+func isNumber(s string) bool {
+	return s != "" && (s[0] == '-' || s[0] >= '0' && s[0] <= '9')
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+// ParseExpr parses a condition expression such as
+// `not repo.archived` or `kind == "software" and genres contains "Games"`.
+func ParseExpr(s string) (Expr, error) {
+	toks, err := lexExprTokens(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &exprError{t.off, fmt.Sprintf("unexpected %s", t.text)}
+	}
+	return e, nil
+}
+
+func (p *exprParser) peek() token { return p.toks[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isWord(word string) bool {
+	t := p.peek()
+	return t.kind == tokWord && t.text == word
+}
+
+// or := and {"or" and}
+func (p *exprParser) or() (Expr, error) {
+	x, err := p.and()
+	for err == nil && p.isWord("or") {
+		p.next()
+		var y Expr
+		if y, err = p.and(); err == nil {
+			x = BinaryExpr{Op: "or", X: x, Y: y}
+		}
+	}
+	return x, err
+}
+
+// and := unary {"and" unary}
+func (p *exprParser) and() (Expr, error) {
+	x, err := p.unary()
+	for err == nil && p.isWord("and") {
+		p.next()
+		var y Expr
+		if y, err = p.unary(); err == nil {
+			x = BinaryExpr{Op: "and", X: x, Y: y}
+		}
+	}
+	return x, err
+}
+
+// unary := "not" unary | "exists" operand | operand [op operand]
+func (p *exprParser) unary() (Expr, error) {
+	if p.isWord("not") || p.isWord("exists") {
+		t := p.next()
+		var x Expr
+		var err error
+		if t.text == "not" {
+			x, err = p.unary()
+		} else {
+			x, err = p.operand()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "not" {
+			return NotExpr{X: x}, nil
+		}
+		return ExistsExpr{X: x}, nil
+	}
+	x, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	isOp := t.kind == tokPunct && t.text != "(" && t.text != ")"
+	if !isOp && !p.isWord("in") && !p.isWord("contains") {
+		return x, nil
+	}
+	p.next()
+	y, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return BinaryExpr{Op: t.text, X: x, Y: y}, nil
+}
+
+func (p *exprParser) operand() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return LitExpr{Value: t.val}, nil
+	case tokWord:
+		switch t.text {
+		case "true":
+			return LitExpr{Value: true}, nil
+		case "false":
+			return LitExpr{Value: false}, nil
+		case "null":
+			return LitExpr{Value: nil}, nil
+		case "not", "in", "contains", "exists", "and", "or":
+			return nil, &exprError{t.off, "unexpected " + t.text}
+		}
+		if isNumber(t.text) {
+			f, err := strconv.ParseFloat(t.text, 64)
+			if err != nil {
+				return nil, &exprError{t.off, "invalid number " + t.text}
+			}
+			return LitExpr{Value: f}, nil
+		}
+		if isPath(t.text) {
+			return PathExpr{Path: t.text}, nil
+		}
+	case tokPunct:
+		if t.text == "(" {
+			e, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if c := p.next(); c.text != ")" {
+				return nil, &exprError{c.off, "missing )"}
+			}
+			return e, nil
+		}
+	case tokEOF:
+		return nil, &exprError{t.off, "unexpected end of expression"}
+	}
+	return nil, &exprError{t.off, "unexpected " + t.text}
+}
+
+// evalExpr evaluates e, resolving paths with lookup.
+func evalExpr(e Expr, lookup func(path string) interface{}) interface{} {
+	switch x := e.(type) {
+	case PathExpr:
+		return lookup(x.Path)
+	case LitExpr:
+		return x.Value
+	case NotExpr:
+		return !truthy(evalExpr(x.X, lookup))
+	case ExistsExpr:
+		return evalExpr(x.X, lookup) != nil
+	case BinaryExpr:
+		a := evalExpr(x.X, lookup)
+		switch x.Op {
+		case "and":
+			return truthy(a) && truthy(evalExpr(x.Y, lookup))
+		case "or":
+			return truthy(a) || truthy(evalExpr(x.Y, lookup))
+		}
+		b := evalExpr(x.Y, lookup)
+		switch x.Op {
+		case "==":
+			return valuesEqual(a, b)
+		case "!=":
+			return !valuesEqual(a, b)
+		case "<":
+			return orderable(a, b) && compareValues(a, b) < 0
+		case ">":
+			return orderable(a, b) && compareValues(a, b) > 0
+		case "in":
+			return contains(b, a)
+		case "contains":
+			return contains(a, b)
+		}
+	}
+	return nil
+}
+
+// truthy reports whether v counts as true: false, null, zero, the empty
+// string and empty arrays or objects are false.
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case []interface{}:
+		return len(x) > 0
+	case map[string]interface{}:
+		return len(x) > 0
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	switch a.(type) {
+	case nil, string, bool:
+		return reflect.TypeOf(a) == reflect.TypeOf(b) && a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// orderable reports whether a and b can be ordered: both numbers or both
+// strings.
+func orderable(a, b interface{}) bool {
+	if _, ok := toFloat(a); ok {
+		_, ok := toFloat(b)
+		return ok
+	}
+	_, sa := a.(string)
+	_, sb := b.(string)
+	return sa && sb
+}
+
+// contains reports whether needle is an element of an array, a key of an
+// object or a substring of a string.
+func contains(haystack, needle interface{}) bool {
+	switch h := haystack.(type) {
+	case []interface{}:
+		for _, v := range h {
+			if valuesEqual(v, needle) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		s, ok := needle.(string)
+		if !ok {
+			return false
+		}
+		_, ok = h[s]
+		return ok
+	case string:
+		s, ok := needle.(string)
+		return ok && strings.Contains(h, s)
+	}
+	return false
+}
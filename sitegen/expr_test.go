@@ -0,0 +1,91 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	vars := map[string]interface{}{
+		"repo": map[string]interface{}{
+			"description":      nil,
+			"stargazers_count": float64(12),
+			"language":         "Go",
+		},
+		"app": map[string]interface{}{
+			"genres": []interface{}{"Games", "Puzzle"},
+		},
+		"apps": []interface{}{map[string]interface{}{"trackName": "Hi"}},
+	}
+	lookup := func(path string) interface{} { return Resolve(vars, path) }
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"repo.description", false},
+		{"not repo.description", true},
+		{"app.genres", true},
+		{"repo.language == \"Go\"", true},
+		{"repo.language != \"Go\"", false},
+		{"repo.stargazers_count > 10", true},
+		{"repo.stargazers_count < 10", false},
+		{"repo.missing < 10", false},
+		{"\"Games\" in app.genres", true},
+		{"not (\"Music\" in app.genres)", true},
+		{"repo.description == null", true},
+		{"app.genres contains \"Puzzle\"", true},
+		{"exists repo.description", false},
+		{"exists repo.language and repo.language == \"Go\"", true},
+		{"repo.stargazers_count > 100 or \"Games\" in app.genres", true},
+		{"not repo.description and repo.stargazers_count < 10", false},
+		{"repo.language==\"Go\"", true},
+		{"apps.0.trackName", true},
+		{"apps.1.trackName", false},
+		{"app.genres.1 == \"Puzzle\"", true},
+		{"(repo.stargazers_count!=12)", false},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got := truthy(evalExpr(e, lookup)); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, s := range []string{"", "a ==", "a = b", "\"open", "(a == b", "a and", "exists", "a !", "$x", "a == \"\\q\"", "1.2.3", "a.-1", "a.1x"} {
+		if _, err := ParseExpr(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestParseIndexPaths(t *testing.T) {
+	for _, src := range []string{
+		"[if apps.0.trackName]\n  apps.0.trackName\n",
+		"[for item in items where item.tags.0 == \"x\"]\n  item.name\n",
+	} {
+		if _, _, err := Parse(strings.NewReader(src)); err != nil {
+			t.Errorf("%q: %v", src, err)
+		}
+	}
+}
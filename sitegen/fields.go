@@ -23,23 +23,34 @@ import (
 
 // Resolve returns the value at path from obj using dotted notation.
 func Resolve(obj interface{}, path string) interface{} {
+	v, _ := lookup(obj, path)
+	return v
+}
+
+// lookup is Resolve that also reports whether path exists in obj, so that
+// a null value can be told from a missing key.
+func lookup(obj interface{}, path string) (interface{}, bool) {
 	parts := strings.Split(path, ".")
 	cur := obj
 	for _, p := range parts {
 		switch c := cur.(type) {
 		case map[string]interface{}:
-			cur = c[p]
+			v, ok := c[p]
+			if !ok {
+				return nil, false
+			}
+			cur = v
 		case []interface{}:
 			idx, err := strconv.Atoi(p)
 			if err != nil || idx < 0 || idx >= len(c) {
-				return nil
+				return nil, false
 			}
 			cur = c[idx]
 		default:
-			return nil
+			return nil, false
 		}
 	}
-	return cur
+	return cur, true
 }
 
 // GetString gets a string value or empty string.
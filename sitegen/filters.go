@@ -0,0 +1,279 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+)
+
+// HTML is markup that field rendering writes as-is instead of escaping.
+// Filters that produce markup, like markdown, return it.
+type HTML string
+
+// Text is plain text that field rendering writes escaped as it is, without
+// the linking and formatting it gives other values. The text filter
+// returns it.
+type Text string
+
+// FilterFunc transforms a field value. Args are the string and number
+// literals written after the filter name.
+type FilterFunc func(v interface{}, args []interface{}) (interface{}, error)
+
+var filters = map[string]FilterFunc{
+	"date":     dateFilter,
+	"truncate": truncateFilter,
+	"markdown": markdownFilter,
+	"upper":    upperFilter,
+	"lower":    lowerFilter,
+	"default":  defaultFilter,
+	"join":     joinFilter,
+	"relative": relativeFilter,
+	"text":     textFilter,
+}
+
+// RegisterFilter makes fn available to field lines as `| name`. Filters
+// must be registered before the .hi file is parsed.
+func RegisterFilter(name string, fn FilterFunc) {
+	filters[name] = fn
+}
+
+// applyFilters runs v through each filter in turn.
+func applyFilters(v interface{}, fs []Filter) (interface{}, error) {
+	for _, f := range fs {
+		fn, ok := filters[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q", f.Name)
+		}
+		var err error
+		if v, err = fn(v, f.Args); err != nil {
+			return nil, fmt.Errorf("filter %s: %w", f.Name, err)
+		}
+	}
+	return v, nil
+}
+
+func stringArg(args []interface{}, i int, def string) (string, error) {
+	if i >= len(args) {
+		return def, nil
+	}
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("argument %d must be a string", i+1)
+	}
+	return s, nil
+}
+
+func intArg(args []interface{}, i int) (int, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing argument %d", i+1)
+	}
+	f, ok := args[i].(float64)
+	if !ok || f != float64(int(f)) {
+		return 0, fmt.Errorf("argument %d must be a whole number", i+1)
+	}
+	return int(f), nil
+}
+
+// parseDate accepts RFC3339 timestamps and plain YYYY-MM-DD dates.
+func parseDate(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dateFilter formats a date with a Go layout, "January 2, 2006" by default.
+// Values that are not dates pass through unchanged.
+func dateFilter(v interface{}, args []interface{}) (interface{}, error) {
+	layout, err := stringArg(args, 0, "January 2, 2006")
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := parseDate(v); ok {
+		return t.Format(layout), nil
+	}
+	return v, nil
+}
+
+// truncateWords shortens s to about n characters with an ellipsis, breaking
+// on the last space unless that would lose more than a quarter of the text.
+func truncateWords(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	lastSpace := -1
+	for i := n - 1; i >= 0; i-- {
+		if r[i] == ' ' {
+			lastSpace = i
+			break
+		}
+	}
+	if lastSpace > n-n/4 {
+		return string(r[:lastSpace]) + "..."
+	}
+	return string(r[:n]) + "..."
+}
+
+func truncateFilter(v interface{}, args []interface{}) (interface{}, error) {
+	n, err := intArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	return truncateWords(fmt.Sprint(v), n), nil
+}
+
+func markdownFilter(v interface{}, args []interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var h bytes.Buffer
+	if err := goldmark.New().Convert([]byte(fmt.Sprint(v)), &h); err != nil {
+		return nil, err
+	}
+	return HTML(h.String()), nil
+}
+
+func upperFilter(v interface{}, args []interface{}) (interface{}, error) {
+	switch s := v.(type) {
+	case string:
+		return strings.ToUpper(s), nil
+	case Text:
+		return Text(strings.ToUpper(string(s))), nil
+	}
+	return v, nil
+}
+
+func lowerFilter(v interface{}, args []interface{}) (interface{}, error) {
+	switch s := v.(type) {
+	case string:
+		return strings.ToLower(s), nil
+	case Text:
+		return Text(strings.ToLower(string(s))), nil
+	}
+	return v, nil
+}
+
+// textFilter marks the value as plain text, so a URL, date or number is
+// written as it is. Arrays are joined with ", ".
+func textFilter(v interface{}, args []interface{}) (interface{}, error) {
+	if arr, ok := v.([]interface{}); ok {
+		parts := make([]string, len(arr))
+		for i, x := range arr {
+			parts[i] = textString(x)
+		}
+		return Text(strings.Join(parts, ", ")), nil
+	}
+	return Text(textString(v)), nil
+}
+
+// defaultFilter replaces null and empty values.
+func defaultFilter(v interface{}, args []interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing argument 1")
+	}
+	switch x := v.(type) {
+	case nil:
+		return args[0], nil
+	case string:
+		if x == "" {
+			return args[0], nil
+		}
+	case Text:
+		if x == "" {
+			return args[0], nil
+		}
+	case []interface{}:
+		if len(x) == 0 {
+			return args[0], nil
+		}
+	}
+	return v, nil
+}
+
+func joinFilter(v interface{}, args []interface{}) (interface{}, error) {
+	sep, err := stringArg(args, 0, ", ")
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return v, nil
+	}
+	parts := make([]string, len(arr))
+	for i, x := range arr {
+		parts[i] = fmt.Sprint(x)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func relativeFilter(v interface{}, args []interface{}) (interface{}, error) {
+	if t, ok := parseDate(v); ok {
+		return relativeTime(t, time.Now()), nil
+	}
+	return v, nil
+}
+
+// relativeTime describes t relative to now, e.g. "3 days ago".
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	const day = 24 * time.Hour
+	var s string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		s = plural(int(d/time.Minute), "minute")
+	case d < day:
+		s = plural(int(d/time.Hour), "hour")
+	case d < 30*day:
+		s = plural(int(d/day), "day")
+	case d < 365*day:
+		s = plural(int(d/(30*day)), "month")
+	default:
+		s = plural(int(d/(365*day)), "year")
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
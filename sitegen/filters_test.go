@@ -0,0 +1,97 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseField(t *testing.T, line string) Field {
+	t.Helper()
+	_, nodes, err := Parse(strings.NewReader(line + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodes[0].(Field)
+}
+
+func TestFieldFilters(t *testing.T) {
+	tests := []struct {
+		line string
+		v    interface{}
+		want interface{}
+	}{
+		{`thing.date_published | date "Jan 2, 2006"`, "2025-09-01", "Sep 1, 2025"},
+		{`repo.updated_at | date`, "2025-03-01T12:00:00Z", "March 1, 2025"},
+		{`repo.name | upper`, "vortex", "VORTEX"},
+		{`repo.description | default "n/a" | upper`, nil, "N/A"},
+		{`app.genres | join " / "`, []interface{}{"Games", "Puzzle"}, "Games / Puzzle"},
+		{`thing.description | truncate 20`, "the quick brown fox jumps", "the quick brown fox..."},
+		{`thing.description | truncate 4`, "abcdefgh", "abcd..."},
+		{`thing.description | markdown`, "*hi*", HTML("<p><em>hi</em></p>\n")},
+		{`thing.title | default "a|b"`, "", "a|b"},
+		{`repo.stargazers_count | text`, 1e6, Text("1000000")},
+		{`app.genres | text | upper`, []interface{}{"Games", nil, 2.5}, Text("GAMES, , 2.5")},
+		{`repo.homepage | text | default "none"`, nil, "none"},
+	}
+	for _, tt := range tests {
+		f := parseField(t, tt.line)
+		got, err := applyFilters(tt.v, f.Filters)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.line, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %#v, want %#v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterFilter(t *testing.T) {
+	RegisterFilter("shout", func(v interface{}, args []interface{}) (interface{}, error) {
+		return GetString(v) + "!", nil
+	})
+	defer delete(filters, "shout")
+	f := parseField(t, "thing.title | shout")
+	if got, _ := applyFilters("hi", f.Filters); got != "hi!" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestUnknownFilter(t *testing.T) {
+	_, _, err := Parse(strings.NewReader("thing.title | shout\n"))
+	if err == nil || err.Error() != `<input>:1:15: unknown filter "shout"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		30 * time.Second:     "just now",
+		-5 * time.Minute:     "5 minutes ago",
+		-24 * time.Hour:      "1 day ago",
+		-24 * 90 * time.Hour: "3 months ago",
+		3 * time.Hour:        "in 3 hours",
+	}
+	for d, want := range tests {
+		if got := relativeTime(now.Add(d), now); got != want {
+			t.Errorf("%v: got %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format parses a .hi file and prints it in canonical form: runs of
+// bindings aligned on = and <<, two-space indentation, sort keys written as
+// key^ and single blank lines between blocks. Comments and includes are
+// kept as written, and so is any front matter.
+func Format(filename string, src []byte) ([]byte, error) {
+	_, n, _, err := splitFrontMatter(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	body := src
+	for i := 0; i < n && len(body) > 0; i++ {
+		if j := bytes.IndexByte(body, '\n'); j >= 0 {
+			body = body[j+1:]
+		} else {
+			body = nil
+		}
+	}
+	lines, err := readLines(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].num += n
+	}
+	front := src[:len(src)-len(body)]
+	p := &parser{file: filename, bound: make(map[string]Pos), macros: make(map[string]Pos), uses: make(map[Pos]string), keepComments: true, keepIncludes: true, trailing: make(map[int]string)}
+	nodes := p.parseBody(lines, "")
+	if err := p.errs.Err(); err != nil {
+		return nil, err
+	}
+	// bindings live outside the body, so merge them back in by line
+	top := make([]Node, 0, len(nodes)+len(p.bindings))
+	top = append(top, nodes...)
+	for _, b := range p.bindings {
+		top = append(top, b)
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Pos().Line < top[j].Pos().Line })
+
+	f := formatter{comments: p.trailing}
+	f.buf.Write(front)
+	if len(front) > 0 && len(top) > 0 {
+		// one blank line after the front matter
+		f.buf.WriteString("\n")
+	}
+	f.nodes(top, 0)
+	return f.buf.Bytes(), nil
+}
+
+type formatter struct {
+	buf      bytes.Buffer
+	comments map[int]string // trailing comments by source line
+}
+
+func (f *formatter) line(indent int, s string) {
+	f.buf.WriteString(strings.Repeat("  ", indent))
+	f.buf.WriteString(s)
+	f.buf.WriteByte('\n')
+}
+
+// code prints a line of code followed by the trailing comment, if any, of
+// source line num.
+func (f *formatter) code(indent int, s string, num int) {
+	if c := f.comments[num]; c != "" {
+		s += " " + c
+	}
+	f.line(indent, s)
+}
+
+// nodes prints a list of sibling nodes, keeping one blank line wherever the
+// source had any.
+func (f *formatter) nodes(nodes []Node, depth int) {
+	for i := 0; i < len(nodes); i++ {
+		if i > 0 && nodes[i].Pos().Line > nodes[i-1].End().Line+1 {
+			f.buf.WriteByte('\n')
+		}
+		if _, ok := nodes[i].(Binding); ok {
+			j := i + 1
+			for j < len(nodes) {
+				_, ok := nodes[j].(Binding)
+				if !ok || nodes[j].Pos().Line != nodes[j-1].End().Line+1 {
+					break
+				}
+				j++
+			}
+			f.bindings(nodes[i:j], depth)
+			i = j - 1
+			continue
+		}
+		f.node(nodes[i], depth)
+	}
+}
+
+// bindings prints a run of adjacent bindings with aligned columns.
+func (f *formatter) bindings(run []Node, depth int) {
+	nameWidth, targetWidth := 0, 0
+	for _, n := range run {
+		b := n.(Binding)
+		if w := len(bindingName(b)); w > nameWidth {
+			nameWidth = w
+		}
+		if w := len(quote(b.Target, "|<")); b.URL != "" && w > targetWidth {
+			targetWidth = w
+		}
+	}
+	for _, n := range run {
+		b := n.(Binding)
+		var s string
+		switch {
+		case b.Glob:
+			s = fmt.Sprintf("%-*s = glob %s", nameWidth, bindingName(b), quote(b.Target, "|<"))
+		case b.URL == "":
+			s = fmt.Sprintf("%-*s = %s", nameWidth, bindingName(b), quote(b.Target, "|<"))
+		default:
+			s = fmt.Sprintf("%-*s = %-*s << %s", nameWidth, bindingName(b), targetWidth, quote(b.Target, "|<"), quote(b.URL, "|"))
+		}
+		for _, t := range b.Transforms {
+			if t.Where != nil {
+				s += " | where " + ExprString(t.Where)
+			} else {
+				s += " | ." + t.Path
+			}
+		}
+		f.code(depth, s, b.Pos().Line)
+	}
+}
+
+func bindingName(b Binding) string {
+	if b.Lazy {
+		return "!" + b.Name
+	}
+	return b.Name
+}
+
+func (f *formatter) node(n Node, depth int) {
+	switch t := n.(type) {
+	case Comment:
+		f.line(depth, t.Text)
+	case Include:
+		f.code(depth, "[include "+quote(t.Path, "[]")+"]", t.Pos().Line)
+	case Section:
+		if !t.Block {
+			f.code(depth, "{"+sectionHeader(t)+": "+t.Text+"}", t.Pos().Line)
+			return
+		}
+		f.line(depth, "{"+sectionHeader(t)+":")
+		for _, l := range strings.Split(t.Text, "\n") {
+			if l == "" {
+				f.buf.WriteByte('\n')
+				continue
+			}
+			f.line(depth+1, l)
+		}
+		f.code(depth, "}", t.End().Line)
+	case Field:
+		s := t.Path
+		for _, fl := range t.Filters {
+			s += " | " + fl.Name
+			for _, a := range fl.Args {
+				s += " " + literal(a)
+			}
+		}
+		f.code(depth, s, t.Pos().Line)
+	case Loop:
+		f.code(depth, loopHeader(t), t.Pos().Line)
+		f.nodes(t.Body, depth+1)
+	case Raw:
+		f.line(depth, t.Text)
+	case Define:
+		f.code(depth, "[define "+t.Name+"("+strings.Join(t.Params, ", ")+")]", t.Pos().Line)
+		f.nodes(t.Body, depth+1)
+	case Use:
+		s := "[use " + t.Name
+		for _, a := range t.Args {
+			s += " " + ExprString(a)
+		}
+		f.code(depth, s+"]", t.Pos().Line)
+	case If:
+		f.code(depth, "[if "+ExprString(t.Cond)+"]", t.Pos().Line)
+		f.nodes(t.Then, depth+1)
+		if len(t.Else) > 0 {
+			// [else] has no node; its comment is the one between the
+			// branches
+			from := t.Pos().Line
+			if len(t.Then) > 0 {
+				from = t.Then[len(t.Then)-1].End().Line
+			}
+			num := 0
+			for n := from + 1; n < t.Else[0].Pos().Line; n++ {
+				if f.comments[n] != "" {
+					num = n
+					break
+				}
+			}
+			for _, c := range t.Between {
+				f.line(depth, c.Text)
+			}
+			f.code(depth, "[else]", num)
+			f.nodes(t.Else, depth+1)
+		}
+	}
+}
+
+// sectionHeader prints a section's id, classes and attributes.
+func sectionHeader(s Section) string {
+	parts := []string{s.ID}
+	for _, c := range s.Classes {
+		parts = append(parts, "."+c)
+	}
+	for _, a := range s.Attrs {
+		if a.Value == "" {
+			parts = append(parts, a.Name)
+			continue
+		}
+		parts = append(parts, a.Name+"="+strconv.Quote(a.Value))
+	}
+	return strings.Join(parts, " ")
+}
+
+func loopHeader(l Loop) string {
+	var b strings.Builder
+	b.WriteString("[for ")
+	b.WriteString(strings.Join(l.Vars, ", "))
+	b.WriteString(" in ")
+	b.WriteString(quote(l.Source, "[],:()"))
+	if l.Where != nil {
+		b.WriteString(" where ")
+		b.WriteString(ExprString(l.Where))
+	}
+	if l.GroupBy != "" {
+		b.WriteString(" group by ")
+		b.WriteString(l.GroupBy)
+	}
+	if l.As != "" {
+		b.WriteString(" as ")
+		b.WriteString(l.As)
+	}
+	if l.Slot != "" {
+		b.WriteString(" slot ")
+		b.WriteString(l.Slot)
+	}
+	if len(l.Sort) > 0 {
+		b.WriteString(": ")
+		b.WriteString(SortString(l.Sort))
+	}
+	if l.Limit > 0 {
+		fmt.Fprintf(&b, " limit %d", l.Limit)
+	}
+	if l.Offset > 0 {
+		fmt.Fprintf(&b, " offset %d", l.Offset)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// quote returns s as a single word if it can be read back as one, or else
+// as a quoted string. special lists the characters that end a word where s
+// is written.
+func quote(s, special string) string {
+	if s == "" || strings.ContainsAny(s, " \t\""+special) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// SortString formats sort keys in canonical form, e.g. "date_published,
+// title^".
+func SortString(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.Path
+		if k.Asc {
+			parts[i] += "^"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func literal(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// precedence levels used to decide where ExprString needs parentheses
+func exprPrec(e Expr) int {
+	switch x := e.(type) {
+	case BinaryExpr:
+		switch x.Op {
+		case "or":
+			return 1
+		case "and":
+			return 2
+		}
+		return 4
+	case NotExpr, ExistsExpr:
+		return 3
+	}
+	return 5
+}
+
+func exprWithin(e Expr, min int) string {
+	if exprPrec(e) < min {
+		return "(" + ExprString(e) + ")"
+	}
+	return ExprString(e)
+}
+
+// ExprString formats an expression as it would be written in a .hi file.
+func ExprString(e Expr) string {
+	switch x := e.(type) {
+	case PathExpr:
+		return x.Path
+	case LitExpr:
+		return literal(x.Value)
+	case NotExpr:
+		return "not " + exprWithin(x.X, 3)
+	case ExistsExpr:
+		return "exists " + exprWithin(x.X, 5)
+	case BinaryExpr:
+		switch x.Op {
+		case "or", "and":
+			p := exprPrec(x)
+			return exprWithin(x.X, p) + " " + x.Op + " " + exprWithin(x.Y, p+1)
+		}
+		return exprWithin(x.X, 5) + " " + x.Op + " " + exprWithin(x.Y, 5)
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const unformatted = `# Sources
+apps = apps.json << https://example.com/apps   |.results|   where kind == "software"
+!languages   =   languages.json << https://example.com/{repo.name}/languages
+things= things.json
+notes =   glob   content/notes/*.md
+
+
+{hero: Hi}
+[include partials/extra.hi]
+{about  .is-wide   tab=About:
+      First paragraph.
+
+      - a list
+}
+[for thing in things: ^ category, title]
+  thing.title | default "untitled"   |  truncate 20
+      # a comment in a loop
+  [if not (thing.url == null or thing.draft) and exists thing.category]
+    thing.url
+
+  [else]
+    thing.category
+[for kind, items in things where exists kind   group by   kind]
+  [for thing in items]
+      thing.title
+[define  card( item,label )]
+    <div class="card">{label}</div>
+    item.title
+[use card  thing   "Pick"]
+[for app in apps   as   app_card slot   sidebar : trackName^]
+[for app in apps.results where kind == "software":  currentVersionReleaseDate   offset 1 limit 3]
+	app.trackName
+	[if app.price]
+			app.formattedPrice
+`
+
+const formatted = `# Sources
+apps       = apps.json      << https://example.com/apps | .results | where kind == "software"
+!languages = languages.json << https://example.com/{repo.name}/languages
+things     = things.json
+notes      = glob content/notes/*.md
+
+{hero: Hi}
+[include partials/extra.hi]
+{about .is-wide tab="About":
+  First paragraph.
+
+  - a list
+}
+[for thing in things: category^, title^]
+  thing.title | default "untitled" | truncate 20
+  # a comment in a loop
+  [if not (thing.url == null or thing.draft) and exists thing.category]
+    thing.url
+  [else]
+    thing.category
+[for kind, items in things where exists kind group by kind]
+  [for thing in items]
+    thing.title
+[define card(item, label)]
+  <div class="card">{label}</div>
+  item.title
+[use card thing "Pick"]
+[for app in apps as app_card slot sidebar: trackName^]
+[for app in apps.results where kind == "software": currentVersionReleaseDate limit 3 offset 1]
+  app.trackName
+  [if app.price]
+    app.formattedPrice
+`
+
+func TestFormat(t *testing.T) {
+	out, err := Format("test.hi", []byte(unformatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != formatted {
+		t.Fatalf("got\n%s\nwant\n%s", out, formatted)
+	}
+	again, err := Format("test.hi", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != formatted {
+		t.Fatalf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestIndexIsFormatted(t *testing.T) {
+	src, err := os.ReadFile("../index.hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Format("index.hi", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(src) {
+		t.Fatalf("index.hi is not formatted; run hi fmt -w index.hi")
+	}
+}
+
+func TestFormatFrontMatter(t *testing.T) {
+	src := "---\ntitle:   About\n---\n{about:   Hi}\nx  =  x.json\n  [bad\n"
+	_, err := Format("about.hi", []byte(src))
+	if err == nil || !strings.HasPrefix(err.Error(), "about.hi:6:") {
+		t.Fatalf("expected an error on line 6, got %v", err)
+	}
+	out, err := Format("about.hi", []byte(src[:strings.Index(src, "  [bad")]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\ntitle:   About\n---\n\n{about: Hi}\nx = x.json\n"; string(out) != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+}
+
+func TestFormatKeepsBlockComments(t *testing.T) {
+	src := `[if x.a]
+  x.a
+# otherwise
+[else]
+  x.b
+[for x in xs]
+  x.title
+  # trailing note about x
+[if x.b]
+  x.c
+  # about a
+[else]
+  a
+`
+	out, err := Format("test.hi", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != src {
+		t.Fatalf("got\n%s\nwant\n%s", out, src)
+	}
+}
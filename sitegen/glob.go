@@ -0,0 +1,121 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+)
+
+// loadGlob loads the files matching pattern, relative to dir, into an
+// array in file name order. JSON files contribute their parsed value;
+// Markdown files become objects holding their front matter fields plus
+// body (rendered HTML), path and slug.
+func loadGlob(dir, pattern string) ([]interface{}, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob %s: %w", pattern, err)
+	}
+	items := []interface{}{}
+	for _, path := range matches {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		switch filepath.Ext(path) {
+		case ".json":
+			var v interface{}
+			if err := json.Unmarshal(src, &v); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			items = append(items, v)
+		case ".md":
+			item, err := loadMarkdown(dir, path, string(src))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		default:
+			return nil, fmt.Errorf("glob %s: %s is not a .md or .json file", pattern, path)
+		}
+	}
+	return items, nil
+}
+
+func loadMarkdown(dir, path, src string) (map[string]interface{}, error) {
+	lines := strings.Split(src, "\n")
+	fields, n, errs := parseFrontMatter(path, lines)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	item := make(map[string]interface{}, len(fields)+3)
+	for _, f := range fields {
+		item[f.Key] = frontMatterValue(f.Value)
+	}
+	body := strings.Join(lines[n:], "\n")
+	var h bytes.Buffer
+	if err := goldmark.New().Convert([]byte(body), &h); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+	item["body"] = HTML(h.String())
+	item["path"] = filepath.ToSlash(rel)
+	item["slug"] = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return item, nil
+}
+
+// frontMatterValue types a front matter value of a Markdown file. Values
+// may be quoted strings, numbers, true, false or [a, b] lists; anything else
+// is kept as a plain string.
+func frontMatterValue(s string) interface{} {
+	switch {
+	case s == "true", s == "false":
+		return s == "true"
+	case strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]"):
+		list := []interface{}{}
+		for _, part := range strings.Split(s[1:len(s)-1], ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, frontMatterValue(part))
+			}
+		}
+		return list
+	case len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0]:
+		if s[0] == '\'' {
+			return s[1 : len(s)-1]
+		}
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
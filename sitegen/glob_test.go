@@ -0,0 +1,84 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGlobBinding(t *testing.T) {
+	bindings, _, err := Parse(strings.NewReader("notes = glob content/notes/*.md\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := bindings[0]; !b.Glob || b.Manual || b.Target != "content/notes/*.md" {
+		t.Fatalf("unexpected binding %+v", b)
+	}
+	if _, _, err := Parse(strings.NewReader("!notes = glob *.md\n")); err == nil || err.Error() != "<input>:1:1: glob binding notes cannot be lazy" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestLoadGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"notes/b-second.md": "---\ntitle: \"Second: the sequel\"\ndate: 2024-05-01\ntags: [go, web]\ndraft: true\nrating: 4.5\n---\n# Hello\n",
+		"notes/a-first.md":  "No front matter.\n",
+		"notes/skip.txt":    "not matched",
+	})
+	items, err := loadGlob(dir, "notes/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %v", items)
+	}
+	first := items[0].(map[string]interface{})
+	if first["slug"] != "a-first" || first["path"] != "notes/a-first.md" || first["body"] != HTML("<p>No front matter.</p>\n") {
+		t.Fatalf("unexpected first item %v", first)
+	}
+	second := items[1].(map[string]interface{})
+	want := map[string]interface{}{
+		"title":  "Second: the sequel",
+		"date":   "2024-05-01",
+		"tags":   []interface{}{"go", "web"},
+		"draft":  true,
+		"rating": 4.5,
+		"body":   HTML("<h1>Hello</h1>\n"),
+		"path":   "notes/b-second.md",
+		"slug":   "b-second",
+	}
+	if !reflect.DeepEqual(second, want) {
+		t.Fatalf("got %v, want %v", second, want)
+	}
+
+	writeFiles(t, dir, map[string]string{"data/a.json": `{"n": 1}`, "data/b.json": `[2]`})
+	items, err = loadGlob(dir, "data/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []interface{}{map[string]interface{}{"n": 1.0}, []interface{}{2.0}}) {
+		t.Fatalf("unexpected json items %v", items)
+	}
+
+	writeFiles(t, dir, map[string]string{"bad/x.md": "---\ntitle: x\n"})
+	if _, err := loadGlob(dir, "bad/*.md"); err == nil || !strings.Contains(err.Error(), "bad/x.md:1:1: unterminated front matter: missing closing ---") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // anything up to whitespace, a quote or punctuation
+	tokString           // a double-quoted string with Go escapes
+	tokPunct            // a single punctuation character
+)
+
+type token struct {
+	kind tokenKind
+	text string // as written
+	val  string // for strings, the unquoted value
+	off  int    // byte offset in the line
+}
+
+// is reports whether t is the punctuation or word s.
+func (t token) is(s string) bool {
+	return (t.kind == tokPunct || t.kind == tokWord) && t.text == s
+}
+
+// lex splits a line of DSL code into tokens. The characters in punct are
+// tokens of their own; any other run of characters between whitespace and
+// quotes is a word, so paths, URLs and operators stay whole. Lexing stops at
+// a trailing comment (see commentStart). The last token is always tokEOF.
+func lex(s, punct string) ([]token, error) {
+	var toks []token
+	end := commentStart(s)
+	for i := 0; i < end; {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			j := closingQuote(s, i)
+			if j < 0 {
+				return nil, &exprError{i, "unterminated string"}
+			}
+			val, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, &exprError{i, "invalid escape in string " + s[i:j+1]}
+			}
+			toks = append(toks, token{tokString, s[i : j+1], val, i})
+			i = j + 1
+		case strings.IndexByte(punct, c) >= 0:
+			toks = append(toks, token{tokPunct, s[i : i+1], "", i})
+			i++
+		default:
+			j := i + 1
+			for j < end && s[j] != ' ' && s[j] != '\t' && s[j] != '"' && strings.IndexByte(punct, s[j]) < 0 {
+				j++
+			}
+			toks = append(toks, token{tokWord, s[i:j], "", i})
+			i = j
+		}
+	}
+	return append(toks, token{kind: tokEOF, off: end}), nil
+}
+
+// closingQuote returns the index of the quote ending the string that starts
+// at s[i], or -1.
+func closingQuote(s string, i int) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j
+		}
+	}
+	return -1
+}
+
+// commentStart returns the offset of the trailing comment in a line of code,
+// or len(s) if there is none. A comment is a # outside quotes that follows
+// whitespace and is followed by whitespace or the end of the line, so URL
+// fragments (page#top) and words like C# are not comments.
+func commentStart(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			j := closingQuote(s, i)
+			if j < 0 {
+				return len(s)
+			}
+			i = j
+		case c == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t') &&
+			(i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t'):
+			return i
+		}
+	}
+	return len(s)
+}
+
+// stripComment splits a line of code from its trailing comment.
+func stripComment(s string) (code, comment string) {
+	i := commentStart(s)
+	return strings.TrimRight(s[:i], " \t"), s[i:]
+}
+
+// tokenValue returns the value written by toks: a quoted string's contents,
+// or the source text they span in s.
+func tokenValue(s string, toks []token) string {
+	if len(toks) == 0 {
+		return ""
+	}
+	if len(toks) == 1 && toks[0].kind == tokString {
+		return toks[0].val
+	}
+	last := toks[len(toks)-1]
+	return s[toks[0].off : last.off+len(last.text)]
+}
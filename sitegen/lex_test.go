@@ -0,0 +1,264 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseCorpus parses each .hi file in testdata/corpus and compares a
+// dump of the result, positions included, with its .want file. The .want
+// files were written by the parser from before the lexer (commit d3e1255),
+// so the corpus checks that files it read still parse the same way.
+// baseline_index.hi is index.hi from before any of the DSL additions.
+func TestParseCorpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/corpus/*.hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no corpus files")
+	}
+	for _, file := range files {
+		bindings, nodes, err := parseTestFile(t, file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		want, err := os.ReadFile(strings.TrimSuffix(file, ".hi") + ".want")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dumpAST(bindings, nodes); got != string(want) {
+			t.Errorf("%s parses differently:\ngot\n%s\nwant\n%s", file, got, want)
+		}
+	}
+}
+
+// dumpAST writes out what the parser read, field by field, for the node
+// types and fields the parser had before the lexer. It leaves out fields
+// added since, so that it doesn't change when an AST struct grows.
+func dumpAST(bindings []Binding, nodes []Node) string {
+	var b strings.Builder
+	for _, bd := range bindings {
+		fmt.Fprintf(&b, "%s binding %s = %q << %q lazy=%v manual=%v glob=%v\n", dumpSpan(bd.Span), bd.Name, bd.Target, bd.URL, bd.Lazy, bd.Manual, bd.Glob)
+		for _, t := range bd.Transforms {
+			fmt.Fprintf(&b, "  | %q where %s\n", t.Path, dumpExpr(t.Where))
+		}
+	}
+	dumpNodes(&b, nodes, "")
+	return b.String()
+}
+
+func dumpNodes(b *strings.Builder, nodes []Node, indent string) {
+	for _, n := range nodes {
+		switch x := n.(type) {
+		case Section:
+			fmt.Fprintf(b, "%s%s section %s classes=%q attrs=%q block=%v text=%q\n", indent, dumpSpan(x.Span), x.ID, x.Classes, x.Attrs, x.Block, x.Text)
+		case Loop:
+			fmt.Fprintf(b, "%s%s loop %q in %q where %s group=%q sort=%v limit=%d offset=%d\n", indent, dumpSpan(x.Span), x.Vars, x.Source, dumpExpr(x.Where), x.GroupBy, x.Sort, x.Limit, x.Offset)
+			dumpNodes(b, x.Body, indent+"  ")
+		case If:
+			fmt.Fprintf(b, "%s%s if %s\n", indent, dumpSpan(x.Span), dumpExpr(x.Cond))
+			dumpNodes(b, x.Then, indent+"  ")
+			fmt.Fprintf(b, "%selse\n", indent)
+			dumpNodes(b, x.Else, indent+"  ")
+		case Comment:
+			fmt.Fprintf(b, "%s%s comment %q\n", indent, dumpSpan(x.Span), x.Text)
+		case Include:
+			fmt.Fprintf(b, "%s%s include %q\n", indent, dumpSpan(x.Span), x.Path)
+		case Define:
+			fmt.Fprintf(b, "%s%s define %s%q\n", indent, dumpSpan(x.Span), x.Name, x.Params)
+			dumpNodes(b, x.Body, indent+"  ")
+		case Use:
+			args := make([]string, len(x.Args))
+			for i, a := range x.Args {
+				args[i] = dumpExpr(a)
+			}
+			fmt.Fprintf(b, "%s%s use %s%v\n", indent, dumpSpan(x.Span), x.Name, args)
+		case Raw:
+			fmt.Fprintf(b, "%s%s raw %q\n", indent, dumpSpan(x.Span), x.Text)
+		case Field:
+			fmt.Fprintf(b, "%s%s field %q", indent, dumpSpan(x.Span), x.Path)
+			for _, f := range x.Filters {
+				fmt.Fprintf(b, " | %s%v", f.Name, f.Args)
+			}
+			b.WriteString("\n")
+		default:
+			fmt.Fprintf(b, "%s%s %T\n", indent, n.Pos(), n)
+		}
+	}
+}
+
+func dumpSpan(s Span) string {
+	return fmt.Sprintf("%d:%d-%d:%d", s.From.Line, s.From.Col, s.To.Line, s.To.Col)
+}
+
+func dumpExpr(e Expr) string {
+	switch x := e.(type) {
+	case nil:
+		return "-"
+	case PathExpr:
+		return x.Path
+	case LitExpr:
+		return fmt.Sprintf("%#v", x.Value)
+	case NotExpr:
+		return "(not " + dumpExpr(x.X) + ")"
+	case ExistsExpr:
+		return "(exists " + dumpExpr(x.X) + ")"
+	case BinaryExpr:
+		return "(" + dumpExpr(x.X) + " " + x.Op + " " + dumpExpr(x.Y) + ")"
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+func TestLex(t *testing.T) {
+	toks, err := lex(`[for x in "a: b \"c\"" where n == 1] # why`, "[],:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range toks {
+		switch tok.kind {
+		case tokString:
+			got = append(got, "str:"+tok.val)
+		case tokEOF:
+			got = append(got, fmt.Sprintf("eof@%d", tok.off))
+		default:
+			got = append(got, tok.text)
+		}
+	}
+	want := `[ for x in str:a: b "c" where n == 1 ] eof@37`
+	if strings.Join(got, " ") != want {
+		t.Fatalf("got %q, want %q", strings.Join(got, " "), want)
+	}
+	if _, err := lex(`x = "open`, "="); err == nil || err.Error() != "unterminated string" {
+		t.Fatalf("got %v, want unterminated string", err)
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	for _, tt := range []struct{ in, code, comment string }{
+		{"thing.title # why", "thing.title", "# why"},
+		{"thing.title\t#", "thing.title", "#"},
+		{"x = x.json << https://example.com/#top", "x = x.json << https://example.com/#top", ""},
+		{"x = x.json << https://example.com/ #top", "x = x.json << https://example.com/ #top", ""},
+		{`t | default "a # b"`, `t | default "a # b"`, ""},
+		{`[if lang == "C#"] # only C#`, `[if lang == "C#"]`, "# only C#"},
+	} {
+		code, comment := stripComment(tt.in)
+		if code != tt.code || comment != tt.comment {
+			t.Errorf("stripComment(%q) = %q, %q; want %q, %q", tt.in, code, comment, tt.code, tt.comment)
+		}
+	}
+}
+
+func TestParseQuoting(t *testing.T) {
+	src := `docs  = "my docs.json" << "https://example.com/search?q=fall in love|top" # docs
+feed  = feed.json << https://example.com/feed#latest | .items
+notes = glob "content/my notes/*.md"
+
+{intro: Use \{braces\} and {curly} text} # the intro
+{about: Sets like {a, b}
+  and more.
+}
+[for x in "a:b" where title == "x in y: z]": n limit 2] # loop
+  x.title # the title
+  [if x.url]
+    x.url
+  [else] # no url
+    x.id
+`
+	bindings, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings) != 3 {
+		t.Fatalf("got %d bindings", len(bindings))
+	}
+	if b := bindings[0]; b.Target != "my docs.json" || b.URL != "https://example.com/search?q=fall in love|top" {
+		t.Errorf("docs = %q << %q", b.Target, b.URL)
+	}
+	if b := bindings[1]; b.URL != "https://example.com/feed#latest" || len(b.Transforms) != 1 || b.Transforms[0].Path != "items" {
+		t.Errorf("feed = %+v", b)
+	}
+	if b := bindings[2]; !b.Glob || b.Target != "content/my notes/*.md" {
+		t.Errorf("notes = %+v", b)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("got %d nodes: %+v", len(nodes), nodes)
+	}
+	if s := nodes[0].(Section); s.Text != `Use \{braces\} and {curly} text` {
+		t.Errorf("intro text %q", s.Text)
+	}
+	if s := nodes[1].(Section); !s.Block || s.Text != "Sets like {a, b}\nand more." {
+		t.Errorf("about = %+v", s)
+	}
+	l := nodes[2].(Loop)
+	if l.Source != "a:b" || ExprString(l.Where) != `title == "x in y: z]"` || SortString(l.Sort) != "n" || l.Limit != 2 {
+		t.Errorf("loop = %+v", l)
+	}
+	if f := l.Body[0].(Field); f.Path != "x.title" {
+		t.Errorf("field path %q", f.Path)
+	}
+	if i := l.Body[1].(If); len(i.Else) != 1 {
+		t.Errorf("if = %+v", i)
+	}
+
+	out, err := Format("test.hi", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `docs  = "my docs.json" << "https://example.com/search?q=fall in love|top" # docs
+feed  = feed.json      << https://example.com/feed#latest | .items
+notes = glob "content/my notes/*.md"
+
+{intro: Use \{braces\} and {curly} text} # the intro
+{about:
+  Sets like {a, b}
+  and more.
+}
+[for x in "a:b" where title == "x in y: z]": n limit 2] # loop
+  x.title # the title
+  [if x.url]
+    x.url
+  [else] # no url
+    x.id
+`
+	if string(out) != want {
+		t.Errorf("Format:\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestParseLexErrors(t *testing.T) {
+	for _, tt := range []struct{ src, want string }{
+		{`x = "x.json`, `<input>:1:5: invalid binding x: unterminated string`},
+		{`x = x.json <<`, `<input>:1:14: missing URL after << in binding x`},
+		{"[for x in xs where (a]\n", `<input>:1:23: invalid where clause: missing )`},
+		{"[for x in xs limit 2 limit 3]\n", `<input>:1:22: duplicate limit in loop header`},
+		{"[for x in xs] x\n", `<input>:1:15: unexpected x after loop header`},
+	} {
+		_, _, err := Parse(strings.NewReader(tt.src))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%q: got %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoopVar(t *testing.T) {
+	src := `[for x in xs]
+  [for k, v in x: k^]
+    loop.parent.index1
+    loop.index
+    [if loop.last]
+      loop.length
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: map[string]interface{}{"xs": []interface{}{
+		map[string]interface{}{"a": 1.0, "b": 2.0},
+		map[string]interface{}{"c": 3.0},
+	}}}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `<section class="section">` +
+		`<div class="box"><section class="section">` +
+		`<div class="box"><p>1</p><p>0</p></div>` +
+		`<div class="box"><p>1</p><p>1</p><p>2</p></div>` +
+		`</section></div>` +
+		`<div class="box"><section class="section">` +
+		`<div class="box"><p>2</p><p>0</p><p>1</p></div>` +
+		`</section></div>` +
+		`</section>`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
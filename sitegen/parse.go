@@ -18,168 +18,976 @@ package sitegen
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-var bindRe = regexp.MustCompile(`^(!?)([A-Za-z0-9_]+)\s*=\s*(.+)$`)
-var eagerRe = regexp.MustCompile(`^([^<\s]+)\s*<<\s*(.+)$`)
+var attrNameRe = regexp.MustCompile(`^[A-Za-z_][-A-Za-z0-9_:.]*$`)
+var elementRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+var defineRe = regexp.MustCompile(`^\[define\s+([A-Za-z_][A-Za-z0-9_]*)\s*(?:\(([^)]*)\))?\s*\]$`)
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 
-// Parse reads a .hi file into bindings and body nodes.
+// srcLine is a raw source line and its 1-based line number.
+type srcLine struct {
+	num  int
+	text string
+}
+
+// parser holds the state of parsing one file. Errors are collected rather
+// than returned so that one pass reports every problem in the file.
+// Included files get their own parser sharing bound and stack.
+type parser struct {
+	file     string
+	bindings []Binding
+	errs     ErrorList
+	bound    map[string]Pos // where each binding name was declared
+	macros   map[string]Pos // where each macro was defined
+	uses     map[Pos]string // the line of each [use], for checkUses' errors
+	stack    []string       // absolute paths of the files being included
+
+	// For Format: keep comments and leave includes unresolved.
+	keepComments bool
+	keepIncludes bool
+	trailing     map[int]string // trailing comments by line number
+}
+
+// span covers from column col of first to the end of last.
+func (p *parser) span(first, last srcLine, col int) Span {
+	return Span{
+		From: Pos{File: p.file, Line: first.num, Col: col},
+		To:   Pos{File: p.file, Line: last.num, Col: len(last.text) + 1},
+	}
+}
+
+func (p *parser) errorf(l srcLine, col int, format string, args ...interface{}) {
+	p.errs = append(p.errs, &ParseError{
+		Pos:    Pos{File: p.file, Line: l.num, Col: col},
+		Msg:    fmt.Sprintf(format, args...),
+		Source: l.text,
+	})
+}
+
+// Parse reads a .hi file into bindings and body nodes. Front matter at the
+// top of the file is checked and skipped; it is not part of the body.
 func Parse(r io.Reader) ([]Binding, []Node, error) {
-	scanner := bufio.NewScanner(r)
-	var bindings []Binding
-	var bodyLines []string
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(strings.TrimSpace(line), "#") {
-			continue
-		}
-		if len(strings.TrimSpace(line)) == 0 {
-			bodyLines = append(bodyLines, line)
-			continue
-		}
-		if m := bindRe.FindStringSubmatch(line); m != nil {
-			lazy := m[1] == "!"
-			rest := m[3]
-			var b Binding
-			b.Name = m[2]
-			if m2 := eagerRe.FindStringSubmatch(rest); m2 != nil {
-				b.Target = strings.TrimSpace(m2[1])
-				b.URL = strings.TrimSpace(m2[2])
-				b.Lazy = lazy
-			} else {
-				b.Target = strings.TrimSpace(rest)
-				b.Manual = true
-			}
-			bindings = append(bindings, b)
-		} else {
-			bodyLines = append(bodyLines, line)
+	return ParseFile("", r)
+}
+
+// ParseFile is like Parse but records filename in the positions of any
+// errors. If the source has errors, the returned error is an ErrorList.
+func ParseFile(filename string, r io.Reader) ([]Binding, []Node, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &parser{file: filename, bound: make(map[string]Pos), macros: make(map[string]Pos), uses: make(map[Pos]string)}
+	if filename != "" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			p.stack = []string{abs}
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, nil, err
+	// front matter is for the page's settings, not part of its body
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.text
 	}
-	nodes, err := parseBody(bodyLines, 0, 0)
-	if err != nil {
+	_, n, errs := pageFrontMatter(filename, texts)
+	p.errs = append(p.errs, errs...)
+	nodes := p.parseBody(lines[n:], "")
+	p.checkUses(nodes)
+	if err := p.errs.Err(); err != nil {
 		return nil, nil, err
 	}
-	return bindings, nodes, nil
+	return p.bindings, nodes, nil
+}
+
+func readLines(r io.Reader) ([]srcLine, error) {
+	scanner := bufio.NewScanner(r)
+	var lines []srcLine
+	for n := 1; scanner.Scan(); n++ {
+		lines = append(lines, srcLine{num: n, text: scanner.Text()})
+	}
+	return lines, scanner.Err()
+}
+
+// isBlank reports whether a line carries nothing for the parser: it is empty
+// or a comment.
+func isBlank(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// code returns the text of l without its trailing comment, keeping the
+// comment for Format.
+func (p *parser) code(l srcLine) string {
+	code, comment := stripComment(l.text)
+	if comment != "" && p.keepComments {
+		p.trailing[l.num] = comment
+	}
+	return code
 }
 
-// parseBody parses body lines recursively expecting indent spaces.
-func parseBody(lines []string, start, indent int) ([]Node, error) {
+// parseBody parses body lines that share the leading whitespace indent.
+func (p *parser) parseBody(lines []srcLine, indent string) []Node {
 	var nodes []Node
-	for i := start; i < len(lines); i++ {
-		line := lines[i]
-		if strings.TrimSpace(line) == "" {
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if isBlank(l.text) {
+			if trimmed := strings.TrimSpace(l.text); trimmed != "" && p.keepComments {
+				nodes = append(nodes, Comment{Span: p.span(l, l, len(indentOf(l.text))+1), Text: trimmed})
+			}
+			continue
+		}
+		ws := indentOf(l.text)
+		if ws != indent || strings.Contains(ws, " ") && strings.Contains(ws, "\t") {
+			switch {
+			case strings.Contains(ws, " ") && strings.Contains(ws, "\t"):
+				p.errorf(l, 1, "mixed tabs and spaces in indentation")
+			case strings.HasPrefix(ws, indent):
+				p.errorf(l, len(ws)+1, "unexpected indent")
+			case strings.HasPrefix(indent, ws):
+				p.errorf(l, len(ws)+1, "unexpected dedent")
+			default:
+				p.errorf(l, 1, "mixed tabs and spaces in indentation: block is indented with %s", describeIndent(indent))
+			}
+			// skip anything nested under the bad line too, but not the
+			// lines after it that sit at the block's own indent
+			nested := ws
+			if len(indent) > len(ws) {
+				nested = indent
+			}
+			_, used := block(lines[i:], nested)
+			i += used - 1
 			continue
 		}
-		curIndent := countIndent(line)
-		if curIndent < indent {
-			return nodes, fmt.Errorf("unexpected dedent")
+		curIndent := len(ws)
+		// Trailing comments go, except in raw HTML and section text: a
+		// section line only loses one if the section still closes without it.
+		switch trimmed := strings.TrimSpace(l.text); {
+		case strings.HasPrefix(trimmed, "<"):
+		case strings.HasPrefix(trimmed, "{"):
+			if code, _ := stripComment(trimmed); !sectionCloses(code) {
+				break
+			}
+			fallthrough
+		default:
+			l.text = p.code(l)
+			lines[i] = l
 		}
-		if curIndent > indent {
-			// handled by recursion from parent
+		trimmed := strings.TrimSpace(l.text)
+		if p.parseBinding(l) {
 			continue
 		}
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}") {
+		if strings.HasPrefix(trimmed, "{") && sectionCloses(trimmed) {
 			inner := strings.TrimSuffix(strings.TrimPrefix(trimmed, "{"), "}")
-			parts := strings.SplitN(inner, ":", 2)
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("invalid section: %s", line)
+			colon := indexUnquoted(inner, ":")
+			if colon < 0 {
+				p.errorf(l, curIndent+1, "invalid section: missing : after section id")
+				continue
 			}
-			nodes = append(nodes, Section{ID: strings.TrimSpace(parts[0]), Text: strings.TrimSpace(parts[1])})
+			sec, ok := p.sectionHeader(l, curIndent+1, inner[:colon])
+			if ok {
+				sec.Span = p.span(l, l, curIndent+1)
+				sec.Text = strings.TrimSpace(inner[colon+1:])
+				nodes = append(nodes, sec)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "{") && indexUnquoted(trimmed, ":") >= 0 {
+			sec, used, ok := p.parseBlockSection(lines[i:], curIndent)
+			if ok {
+				nodes = append(nodes, sec)
+			}
+			i += used - 1
 			continue
 		}
 		if strings.HasPrefix(trimmed, "[for ") {
-			loop, used, err := parseLoop(lines[i:], indent)
-			if err != nil {
-				return nil, err
+			loop, used, ok := p.parseLoop(lines[i:], indent)
+			if ok {
+				nodes = append(nodes, loop)
+			}
+			i += used - 1
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[include ") && strings.HasSuffix(trimmed, "]") {
+			path, ok := p.includePath(l)
+			if !ok {
+				continue
+			}
+			if p.keepIncludes {
+				nodes = append(nodes, Include{Span: p.span(l, l, curIndent+1), Path: path})
+				continue
+			}
+			nodes = append(nodes, p.include(l, curIndent+len("[include ")+1, path)...)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[define ") {
+			def, used, ok := p.parseDefine(lines[i:], indent)
+			if ok {
+				nodes = append(nodes, def)
 			}
-			nodes = append(nodes, loop)
 			i += used - 1
 			continue
 		}
-		// field line
-		nodes = append(nodes, Field{Path: trimmed})
+		if strings.HasPrefix(trimmed, "[use ") {
+			if use, ok := p.parseUse(l, curIndent); ok {
+				nodes = append(nodes, use)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "<") {
+			nodes = append(nodes, Raw{Span: p.span(l, l, curIndent+1), Text: trimmed})
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[if ") {
+			cond, used, ok := p.parseIf(lines[i:], indent)
+			if ok {
+				nodes = append(nodes, cond)
+			}
+			i += used - 1
+			continue
+		}
+		if trimmed == "[else]" {
+			p.errorf(l, curIndent+1, "[else] without [if]")
+			_, used := block(lines[i:], indent)
+			i += used - 1
+			continue
+		}
+		if f, ok := p.parseField(l, curIndent); ok {
+			nodes = append(nodes, f)
+		}
 	}
-	return nodes, nil
+	return nodes
 }
 
-func parseLoop(lines []string, indent int) (Loop, int, error) {
-	header := strings.TrimSpace(lines[0])
-	end := strings.Index(header, "]")
-	if end < 0 {
-		return Loop{}, 0, fmt.Errorf("missing ] in loop header")
+// parseBinding parses l if it is a binding, `name = target`, and reports
+// whether it was one. The target and URL can be quoted to hold spaces or
+// a |.
+func (p *parser) parseBinding(l srcLine) bool {
+	lazy := strings.HasPrefix(l.text, "!")
+	n := len(l.text) - len(strings.TrimLeft(strings.TrimPrefix(l.text, "!"), "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_"))
+	eq := n + len(indentOf(l.text[n:]))
+	if n == 0 || n == 1 && lazy || !strings.HasPrefix(l.text[eq:], "=") || strings.HasPrefix(l.text[eq:], "==") {
+		return false
+	}
+	b := Binding{Span: p.span(l, l, 1), Name: l.text[:n], Lazy: lazy}
+	if lazy {
+		b.Name = b.Name[1:]
+	}
+	toks, err := lex(l.text, "=|<")
+	if err != nil {
+		p.errorf(l, err.(*exprError).off+1, "invalid binding %s: %v", b.Name, err)
+		return true
 	}
-	inner := strings.TrimSpace(header[1:end]) // remove []
-	if !strings.HasPrefix(inner, "for ") {
-		return Loop{}, 0, fmt.Errorf("invalid loop header")
+	if prev, ok := p.bound[b.Name]; ok {
+		p.errorf(l, 1, "binding %s redeclared; previously declared at %s", b.Name, prev)
+		return true
+	}
+	p.bound[b.Name] = b.Pos()
+	// toks[0] is the name and toks[1] the =
+	rest := toks[2 : len(toks)-1]
+	for i, t := range rest {
+		if t.is("|") {
+			transforms, ok := p.parseTransforms(l, t.off+1)
+			if !ok {
+				return true
+			}
+			b.Transforms, rest = transforms, rest[:i]
+			break
+		}
 	}
-	inner = strings.TrimPrefix(inner, "for ")
-	parts := strings.SplitN(inner, ":", 2)
-	left := parts[0]
-	sortSpec := ""
-	if len(parts) == 2 {
-		sortSpec = strings.TrimSpace(parts[1])
-		// Handle prefix ^ for ascending sort on all fields
-		if strings.HasPrefix(sortSpec, "^") {
-			sortSpec = strings.TrimSpace(strings.TrimPrefix(sortSpec, "^"))
-			// Add ^ suffix to each field for ascending sort
-			fields := strings.Split(sortSpec, ",")
-			for i, field := range fields {
-				fields[i] = strings.TrimSpace(field) + "^"
+	target := rest
+	for i := 0; i+1 < len(rest); i++ {
+		if rest[i].is("<") && rest[i+1].is("<") && rest[i+1].off == rest[i].off+1 {
+			target, b.URL = rest[:i], tokenValue(l.text, rest[i+2:])
+			if b.URL == "" {
+				p.errorf(l, rest[i].off+3, "missing URL after << in binding %s", b.Name)
+				return true
 			}
-			sortSpec = strings.Join(fields, ", ")
+			break
+		}
+	}
+	switch {
+	case len(target) == 0:
+		p.errorf(l, toks[1].off+2, "missing target in binding %s", b.Name)
+		return true
+	case b.URL == "" && len(target) > 1 && target[0].is("glob"):
+		if lazy {
+			p.errorf(l, 1, "glob binding %s cannot be lazy", b.Name)
+			return true
 		}
+		b.Target, b.Glob, b.Lazy = tokenValue(l.text, target[1:]), true, false
+	case b.URL == "":
+		b.Target, b.Manual, b.Lazy = tokenValue(l.text, target), true, false
+	default:
+		b.Target = tokenValue(l.text, target)
 	}
-	inParts := strings.Split(left, " in ")
-	if len(inParts) != 2 {
-		return Loop{}, 0, fmt.Errorf("invalid loop header")
+	p.bindings = append(p.bindings, b)
+	return true
+}
+
+// parseTransforms parses the `| .path | where <expr>` steps that start at
+// byte offset off of the binding line.
+func (p *parser) parseTransforms(l srcLine, off int) ([]Transform, bool) {
+	var ts []Transform
+	for {
+		rest := l.text[off:]
+		part := rest
+		bar := indexUnquoted(rest, "|")
+		if bar >= 0 {
+			part = rest[:bar]
+		}
+		step := strings.TrimSpace(part)
+		col := off + strings.Index(part, step) + 1
+		switch {
+		case strings.HasPrefix(step, ".") && len(step) > 1 && !strings.ContainsAny(step, " \t"):
+			ts = append(ts, Transform{Path: step[1:]})
+		case strings.HasPrefix(step, "where "):
+			where, err := ParseExpr(step[len("where "):])
+			if err != nil {
+				col += len("where ")
+				if e, ok := err.(*exprError); ok {
+					col += e.off
+				}
+				p.errorf(l, col, "invalid where clause: %v", err)
+				return nil, false
+			}
+			ts = append(ts, Transform{Where: where})
+		default:
+			p.errorf(l, col, "invalid transform %q: expected .<path> or where <expr>", step)
+			return nil, false
+		}
+		if bar < 0 {
+			return ts, true
+		}
+		off += bar + 1
 	}
-	vars := strings.Split(strings.TrimSpace(inParts[0]), ",")
-	for i := range vars {
-		vars[i] = strings.TrimSpace(vars[i])
+}
+
+// parseField parses a field line: a path followed by optional pipe filters.
+func (p *parser) parseField(l srcLine, indent int) (Field, bool) {
+	text := l.text[indent:]
+	bar := indexUnquoted(text, "|")
+	if bar < 0 {
+		return Field{Span: p.span(l, l, indent+1), Path: strings.TrimSpace(text)}, true
 	}
-	source := strings.TrimSpace(inParts[1])
-	loop := Loop{Vars: vars, Source: source, Sort: ParseSort(sortSpec)}
-	// parse body
-	bodyIndent := indent + 2
-	var bodyLines []string
-	used := 1
-	for ; used < len(lines); used++ {
-		line := lines[used]
+	f := Field{Span: p.span(l, l, indent+1), Path: strings.TrimSpace(text[:bar])}
+	for bar >= 0 {
+		off := indent + bar + 1
+		text = text[bar+1:]
+		bar = indexUnquoted(text, "|")
+		part := text
+		if bar >= 0 {
+			part = text[:bar]
+		}
+		toks, err := lex(part, "")
+		if err != nil {
+			p.errorf(l, off+err.(*exprError).off+1, "invalid filter: %v", err)
+			return Field{}, false
+		}
+		name := toks[0]
+		if name.kind != tokWord || !identRe.MatchString(name.text) {
+			p.errorf(l, off+name.off+1, "expected filter name after |")
+			return Field{}, false
+		}
+		if _, ok := filters[name.text]; !ok {
+			p.errorf(l, off+name.off+1, "unknown filter %q", name.text)
+			return Field{}, false
+		}
+		fl := Filter{Name: name.text}
+		for _, t := range toks[1 : len(toks)-1] {
+			switch {
+			case t.kind == tokString:
+				fl.Args = append(fl.Args, t.val)
+			case isNumber(t.text):
+				n, err := strconv.ParseFloat(t.text, 64)
+				if err != nil {
+					p.errorf(l, off+t.off+1, "invalid number %s", t.text)
+					return Field{}, false
+				}
+				fl.Args = append(fl.Args, n)
+			default:
+				p.errorf(l, off+t.off+1, "filter arguments must be strings or numbers")
+				return Field{}, false
+			}
+		}
+		f.Filters = append(f.Filters, fl)
+	}
+	return f, true
+}
+
+// sectionCloses reports whether a line opening a section also closes it: it
+// ends with } and its braces balance. Braces can be escaped as \{ and \}.
+func sectionCloses(s string) bool {
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+		default:
+			continue
+		}
+		last = i
+	}
+	return len(s) > 0 && last == len(s)-1 && s[last] == '}' && depth <= 0
+}
+
+// parseBlockSection parses a section whose markdown body spans several
+// lines, from "{id:" up to a line holding only "}". Body lines are taken
+// verbatim, so "#" starts a markdown heading rather than a comment.
+func (p *parser) parseBlockSection(lines []srcLine, indent int) (Section, int, bool) {
+	l := lines[0]
+	inner := strings.TrimPrefix(strings.TrimSpace(l.text), "{")
+	colon := indexUnquoted(inner, ":")
+	sec, ok := p.sectionHeader(l, indent+1, inner[:colon])
+	sec.Block = true
+	first := strings.TrimSpace(inner[colon+1:])
+	var body []string
+	for i := 1; i < len(lines); i++ {
+		if code, _ := stripComment(lines[i].text); strings.TrimSpace(code) == "}" {
+			p.code(lines[i])
+			if !ok {
+				return Section{}, i + 1, false
+			}
+			sec.Text = strings.TrimSpace(first + "\n" + dedent(body))
+			sec.Span = p.span(l, lines[i], indent+1)
+			return sec, i + 1, true
+		}
+		body = append(body, lines[i].text)
+	}
+	if ok {
+		p.errorf(l, indent+1, "unterminated section %s: missing closing }", sec.ID)
+	}
+	return Section{}, len(lines), false
+}
+
+// sectionHeader parses the part of a section header before the colon: the
+// id, then .class names, name="value" attributes and bare flags. col is the
+// column of the opening brace.
+func (p *parser) sectionHeader(l srcLine, col int, header string) (Section, bool) {
+	var sec Section
+	off := col // off+1 is the column of header[0]
+	for header != "" {
+		n := len(header)
+		header = strings.TrimLeft(header, " \t")
+		off += n - len(header)
+		if header == "" {
+			break
+		}
+		end := indexUnquoted(header, " ")
+		if t := indexUnquoted(header, "\t"); t >= 0 && (end < 0 || t < end) {
+			end = t
+		}
+		if end < 0 {
+			end = len(header)
+		}
+		tok := header[:end]
+		switch name, value, hasValue := strings.Cut(tok, "="); {
+		case sec.ID == "":
+			sec.ID = tok
+		case strings.HasPrefix(tok, ".") && len(tok) > 1:
+			sec.Classes = append(sec.Classes, tok[1:])
+		case !attrNameRe.MatchString(name):
+			p.errorf(l, off+1, "invalid section attribute %q", tok)
+			return Section{}, false
+		case !hasValue:
+			sec.Attrs = append(sec.Attrs, Attr{Name: name})
+		default:
+			if strings.HasPrefix(value, `"`) {
+				u, err := strconv.Unquote(value)
+				if err != nil {
+					p.errorf(l, off+len(name)+2, "invalid value for section attribute %s: %s", name, value)
+					return Section{}, false
+				}
+				value = u
+			}
+			sec.Attrs = append(sec.Attrs, Attr{Name: name, Value: value})
+		}
+		header = header[end:]
+		off += end
+	}
+	if sec.ID == "" {
+		p.errorf(l, col, "invalid section: missing section id")
+		return Section{}, false
+	}
+	if el, ok := sec.Attr("element"); ok && !elementRe.MatchString(el) {
+		p.errorf(l, col, "invalid element %q for section %s", el, sec.ID)
+		return Section{}, false
+	}
+	// a slotted section's output goes to the layout, outside the tab wrappers
+	if slot, ok := sec.Attr("slot"); ok && slot != "content" {
+		if _, tab := sec.Attr("tab"); tab {
+			p.errorf(l, col, "section %s cannot be both a tab and in slot %s", sec.ID, slot)
+			return Section{}, false
+		}
+	}
+	return sec, true
+}
+
+// dedent joins lines after removing the indentation they all share.
+func dedent(lines []string) string {
+	common := -1
+	for _, line := range lines {
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
-		ind := countIndent(line)
-		if ind < bodyIndent {
+		if n := len(indentOf(line)); common < 0 || n < common {
+			common = n
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			line = ""
+		} else if common > 0 {
+			line = line[common:]
+		}
+		out[i] = line
+	}
+	return strings.Join(out, "\n")
+}
+
+// includePath returns the path of an [include path] line, which may be
+// quoted.
+func (p *parser) includePath(l srcLine) (string, bool) {
+	toks, err := lex(l.text, "[]")
+	if err != nil {
+		p.errorf(l, err.(*exprError).off+1, "invalid include: %v", err)
+		return "", false
+	}
+	// [ include <path> ] EOF
+	path := toks[2 : len(toks)-2]
+	if len(path) > 1 && path[0].kind == tokString {
+		p.errorf(l, path[1].off+1, "unexpected %s after include path", path[1].text)
+		return "", false
+	}
+	return tokenValue(l.text, path), true
+}
+
+// include parses the file at path, relative to the including file, and
+// returns its body nodes to be spliced in place of the directive. Its
+// bindings join ours; its errors keep their own file name.
+func (p *parser) include(l srcLine, col int, path string) []Node {
+	if path == "" {
+		p.errorf(l, col, "missing path in include")
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.file), path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		p.errorf(l, col, "include %s: %v", path, err)
+		return nil
+	}
+	for i, f := range p.stack {
+		if f == abs {
+			chain := make([]string, 0, len(p.stack)-i+1)
+			for _, s := range p.stack[i:] {
+				chain = append(chain, filepath.Base(s))
+			}
+			chain = append(chain, filepath.Base(abs))
+			p.errorf(l, col, "include cycle: %s", strings.Join(chain, " -> "))
+			return nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if pe, ok := err.(*os.PathError); ok {
+			err = pe.Err
+		}
+		p.errorf(l, col, "include %s: %v", path, err)
+		return nil
+	}
+	lines, _ := readLines(bytes.NewReader(data))
+	stack := append(append([]string{}, p.stack...), abs)
+	child := &parser{file: path, bound: p.bound, macros: p.macros, uses: p.uses, stack: stack}
+	nodes := child.parseBody(lines, "")
+	p.bindings = append(p.bindings, child.bindings...)
+	p.errs = append(p.errs, child.errs...)
+	return nodes
+}
+
+// block returns the lines following lines[0] that are indented deeper than
+// indent, along with the number of lines consumed including lines[0].
+// Depth is judged by the width of the leading whitespace; parseBody checks
+// that the lines are indented consistently.
+// Blank and comment lines inside the block belong to it; trailing ones are
+// left for the caller.
+func block(lines []srcLine, indent string) ([]srcLine, int) {
+	used := 1
+	for i := 1; i < len(lines); i++ {
+		deeper := len(indentOf(lines[i].text)) > len(indent)
+		if isBlank(lines[i].text) {
+			// a comment indented under the block belongs to it
+			if deeper && strings.TrimSpace(lines[i].text) != "" {
+				used = i + 1
+			}
+			continue
+		}
+		if !deeper {
 			break
 		}
-		bodyLines = append(bodyLines, line)
+		used = i + 1
 	}
-	nodes, err := parseBody(bodyLines, 0, bodyIndent)
+	return lines[1:used], used
+}
+
+// parseLoop parses a loop header and its body. On a malformed header it
+// reports an error and still consumes the body so parsing can continue.
+func (p *parser) parseLoop(lines []srcLine, indent string) (Loop, int, bool) {
+	l := lines[0]
+	bodyLines, used := block(lines, indent)
+	loop := Loop{Span: p.span(l, lines[used-1], len(indent)+1)}
+	if !p.loopHeader(l, indent, &loop) {
+		return Loop{}, used, false
+	}
+	loop.Body = p.parseBody(bodyLines, bodyIndent(bodyLines))
+	return loop, used, true
+}
+
+// loopHeader parses [for <vars> in <source> ...] into loop. The clauses
+// after the source (as, where, group by, the sort keys after :, limit and
+// offset) are found by token, so a quoted source or a where clause can hold
+// any of their keywords.
+func (p *parser) loopHeader(l srcLine, indent string, loop *Loop) bool {
+	toks, err := lex(l.text, "[],:()")
 	if err != nil {
-		return Loop{}, 0, err
+		p.errorf(l, err.(*exprError).off+1, "invalid loop header: %v", err)
+		return false
+	}
+	closed := false
+	for _, t := range toks {
+		closed = closed || t.is("]")
+	}
+	if !closed {
+		p.errorf(l, len(l.text)+1, "missing ] in loop header")
+		return false
+	}
+	// toks[0:2] are [ and for
+	i := 2
+	for {
+		if toks[i].kind != tokWord || toks[i].is("in") {
+			break
+		}
+		loop.Vars = append(loop.Vars, toks[i].text)
+		if !toks[i+1].is(",") {
+			i++
+			break
+		}
+		i += 2
+	}
+	if len(loop.Vars) == 0 || !toks[i].is("in") || toks[i+1].kind != tokWord && toks[i+1].kind != tokString || !clauseAt(toks, i+2) {
+		p.errorf(l, len(indent)+1, "invalid loop header: expected [for <vars> in <source>]")
+		return false
 	}
-	loop.Body = nodes
-	return loop, used, nil
+	loop.Source = tokenValue(l.text, toks[i+1:i+2])
+	seen := make(map[string]bool)
+	asCol := 0
+	for i += 2; !toks[i].is("]"); {
+		t := toks[i]
+		clause := t.text
+		if t.is("group") {
+			clause = "group by"
+		}
+		if seen[clause] {
+			p.errorf(l, t.off+1, "duplicate %s in loop header", clause)
+			return false
+		}
+		seen[clause] = true
+		end := i + 1
+		switch {
+		case t.is("where"):
+			for depth := 0; depth > 0 || !clauseAt(toks, end); end++ {
+				switch {
+				case toks[end].kind == tokEOF:
+					p.errorf(l, toks[end].off+1, "invalid where clause: missing )")
+					return false
+				case toks[end].is("("):
+					depth++
+				case toks[end].is(")"):
+					depth--
+				}
+			}
+			cond := l.text[toks[i+1].off:toks[end].off]
+			where, err := ParseExpr(cond)
+			if err != nil {
+				col := toks[i+1].off + 1
+				if e, ok := err.(*exprError); ok {
+					col += e.off
+				}
+				p.errorf(l, col, "invalid where clause: %v", err)
+				return false
+			}
+			loop.Where = where
+		case t.is("as"):
+			if !identRe.MatchString(toks[i+1].text) || toks[i+1].kind != tokWord {
+				p.errorf(l, toks[i+1].off+1, "invalid presentation %q: expected as <name>", toks[i+1].text)
+				return false
+			}
+			loop.As = toks[i+1].text
+			asCol = toks[i+1].off + 1
+			end = i + 2
+		case t.is("slot"):
+			if !identRe.MatchString(toks[i+1].text) || toks[i+1].kind != tokWord {
+				p.errorf(l, toks[i+1].off+1, "invalid slot %q: expected slot <name>", toks[i+1].text)
+				return false
+			}
+			loop.Slot = toks[i+1].text
+			end = i + 2
+		case clause == "group by":
+			if toks[i+2].kind != tokWord || !clauseAt(toks, i+3) {
+				p.errorf(l, t.off+1, "invalid group by: expected group by <path>")
+				return false
+			}
+			loop.GroupBy = toks[i+2].text
+			end = i + 3
+		case t.is(":"):
+			for !clauseAt(toks, end) {
+				end++
+			}
+			sortSpec := strings.TrimSpace(l.text[t.off+1 : toks[end].off])
+			// Handle prefix ^ for ascending sort on all fields
+			if strings.HasPrefix(sortSpec, "^") {
+				sortSpec = strings.TrimSpace(strings.TrimPrefix(sortSpec, "^"))
+				// Add ^ suffix to each field for ascending sort
+				fields := strings.Split(sortSpec, ",")
+				for i, field := range fields {
+					fields[i] = strings.TrimSpace(field) + "^"
+				}
+				sortSpec = strings.Join(fields, ", ")
+			}
+			loop.Sort = ParseSort(sortSpec)
+		case t.is("limit"), t.is("offset"):
+			arg := toks[i+1]
+			n, err := strconv.Atoi(arg.text)
+			if arg.kind != tokWord || err != nil || n < 0 || (t.is("limit") && n == 0) {
+				p.errorf(l, arg.off+1, "invalid %s %q", t.text, arg.text)
+				return false
+			}
+			if t.is("limit") {
+				loop.Limit = n
+			} else {
+				loop.Offset = n
+			}
+			end = i + 2
+		default:
+			p.errorf(l, t.off+1, "invalid loop header: unexpected %s", t.text)
+			return false
+		}
+		i = end
+	}
+	if t := toks[i+1]; t.kind != tokEOF {
+		p.errorf(l, t.off+1, "unexpected %s after loop header", t.text)
+		return false
+	}
+	if loop.GroupBy != "" && len(loop.Vars) != 2 {
+		p.errorf(l, len(indent)+1, "group by needs two loop vars: [for <key>, <items> in %s group by %s]", loop.Source, loop.GroupBy)
+		return false
+	}
+	if loop.As == "filter_grid" && loop.GroupBy == "" {
+		p.errorf(l, asCol, "filter_grid needs a group by loop: [for <key>, <items> in %s group by <path> as filter_grid]", loop.Source)
+		return false
+	}
+	return true
+}
+
+// clauseAt reports whether toks[i] ends the clause before it: it is the
+// closing ], the : before the sort keys or starts another clause.
+func clauseAt(toks []token, i int) bool {
+	t := toks[i]
+	switch {
+	case t.kind == tokEOF, t.is("]"), t.is(":"), t.is("where"), t.is("as"), t.is("slot"):
+		return true
+	case t.is("group"):
+		return toks[i+1].is("by")
+	case t.is("limit"), t.is("offset"):
+		return toks[i+1].kind == tokWord && clauseAt(toks, i+2)
+	}
+	return false
 }
 
-func countIndent(s string) int {
-	c := 0
-	for _, ch := range s {
-		if ch == ' ' {
-			c++
-		} else {
+// parseDefine parses a [define name(params)] block.
+func (p *parser) parseDefine(lines []srcLine, indent string) (Define, int, bool) {
+	l := lines[0]
+	bodyLines, used := block(lines, indent)
+	m := defineRe.FindStringSubmatch(strings.TrimSpace(l.text))
+	if m == nil {
+		p.errorf(l, len(indent)+1, "invalid define: expected [define <name>(<params>)]")
+		return Define{}, used, false
+	}
+	def := Define{Span: p.span(l, lines[used-1], len(indent)+1), Name: m[1]}
+	for _, param := range strings.Split(m[2], ",") {
+		param = strings.TrimSpace(param)
+		if param == "" && !strings.Contains(m[2], ",") {
 			break
 		}
+		if !identRe.MatchString(param) {
+			p.errorf(l, strings.Index(l.text, "(")+2, "invalid parameter %q in define %s", param, def.Name)
+			return Define{}, used, false
+		}
+		def.Params = append(def.Params, param)
+	}
+	if prev, ok := p.macros[def.Name]; ok {
+		p.errorf(l, len(indent)+1, "macro %s redefined; previously defined at %s", def.Name, prev)
+		return Define{}, used, false
+	}
+	p.macros[def.Name] = def.Pos()
+	def.Body = p.parseBody(bodyLines, bodyIndent(bodyLines))
+	return def, used, true
+}
+
+// parseUse parses a [use name args...] line. Arguments are paths or
+// literals separated by spaces.
+func (p *parser) parseUse(l srcLine, indent int) (Use, bool) {
+	text := strings.TrimSpace(l.text)
+	if !strings.HasSuffix(text, "]") {
+		p.errorf(l, len(l.text)+1, "missing ] in use")
+		return Use{}, false
+	}
+	inner := text[len("[use ") : len(text)-1]
+	fields := strings.Fields(inner)
+	if len(fields) == 0 || !identRe.MatchString(fields[0]) {
+		p.errorf(l, indent+1, "invalid use: expected [use <name> <args>]")
+		return Use{}, false
+	}
+	use := Use{Span: p.span(l, l, indent+1), Name: fields[0]}
+	p.uses[use.Pos()] = l.text
+	off := strings.Index(inner, use.Name) + len(use.Name)
+	col := indent + len("[use ") + off + 1 // column of args[0]
+	toks, err := lexExprTokens(inner[off:])
+	if err != nil {
+		p.errorf(l, col+err.(*exprError).off, "invalid argument: %v", err)
+		return Use{}, false
+	}
+	ep := &exprParser{toks: toks}
+	for ep.peek().kind != tokEOF {
+		t := ep.peek()
+		arg, err := ep.operand()
+		if err == nil {
+			switch arg.(type) {
+			case PathExpr, LitExpr:
+			default:
+				err = &exprError{t.off, "expected a path or literal"}
+			}
+		}
+		if err != nil {
+			p.errorf(l, col+err.(*exprError).off, "invalid argument: %v", err)
+			return Use{}, false
+		}
+		use.Args = append(use.Args, arg)
+	}
+	return use, true
+}
+
+// checkUses reports uses of macros that are not defined anywhere in the
+// file or its includes, or that pass the wrong number of arguments.
+func (p *parser) checkUses(nodes []Node) {
+	defs := macroDefs(nodes)
+	InspectAll(nodes, func(n Node) bool {
+		u, ok := n.(Use)
+		if !ok {
+			return true
+		}
+		d, ok := defs[u.Name]
+		switch {
+		case !ok:
+			p.errs = append(p.errs, &ParseError{Pos: u.Pos(), Msg: "undefined macro " + u.Name, Source: p.uses[u.Pos()]})
+		case len(u.Args) != len(d.Params):
+			p.errs = append(p.errs, &ParseError{Pos: u.Pos(), Msg: fmt.Sprintf("macro %s takes %s, got %d", u.Name, plural(len(d.Params), "argument"), len(u.Args)), Source: p.uses[u.Pos()]})
+		}
+		return true
+	})
+}
+
+// parseIf parses an [if <expr>] block and an optional [else] block at the
+// same indentation.
+func (p *parser) parseIf(lines []srcLine, indent string) (If, int, bool) {
+	l := lines[0]
+	thenLines, used := block(lines, indent)
+	header := strings.TrimSpace(l.text)
+	if !strings.HasSuffix(header, "]") {
+		p.errorf(l, len(l.text)+1, "missing ] in if header")
+		return If{}, used, false
+	}
+	cond, err := ParseExpr(header[len("[if ") : len(header)-1])
+	if err != nil {
+		col := len(indent) + len("[if ") + 1
+		if e, ok := err.(*exprError); ok {
+			col += e.off
+		}
+		p.errorf(l, col, "invalid condition: %v", err)
+		return If{}, used, false
+	}
+	n := If{Cond: cond, Then: p.parseBody(thenLines, bodyIndent(thenLines))}
+	// look past blank lines for a matching [else], keeping the comments
+	// between the branches for Format
+	next := used
+	var between []Comment
+	for next < len(lines) && isBlank(lines[next].text) {
+		if trimmed := strings.TrimSpace(lines[next].text); trimmed != "" && p.keepComments {
+			between = append(between, Comment{Span: p.span(lines[next], lines[next], len(indentOf(lines[next].text))+1), Text: trimmed})
+		}
+		next++
+	}
+	if next < len(lines) && indentOf(lines[next].text) == indent && strings.TrimSpace(p.code(lines[next])) == "[else]" {
+		n.Between = between
+		elseLines, elseUsed := block(lines[next:], indent)
+		n.Else = p.parseBody(elseLines, bodyIndent(elseLines))
+		used = next + elseUsed
+	}
+	n.Span = p.span(l, lines[used-1], len(indent)+1)
+	return n, used, true
+}
+
+// indexUnquoted is like strings.Index but skips over double-quoted strings.
+func indexUnquoted(s, sub string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(s[i:], sub):
+			return i
+		}
+	}
+	return -1
+}
+
+// indentOf returns the leading spaces and tabs of s.
+func indentOf(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// bodyIndent returns the indentation of the first line of a block that is
+// not blank or a comment; the rest of the block must match it.
+func bodyIndent(lines []srcLine) string {
+	for _, l := range lines {
+		if !isBlank(l.text) {
+			return indentOf(l.text)
+		}
+	}
+	return ""
+}
+
+func describeIndent(indent string) string {
+	if strings.HasPrefix(indent, "\t") {
+		return "tabs"
 	}
-	return c
+	return "spaces"
 }
 
 // ParseSort parses sort specification.
@@ -0,0 +1,402 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorsCollected(t *testing.T) {
+	src := `things = things.json
+
+{things A list without a colon}
+[for thing things: title]
+  thing.title
+[for repo in repos: stargazers_count
+  repo.name
+`
+	_, _, err := ParseFile("index.hi", strings.NewReader(src))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	want := []string{
+		"index.hi:3:1: invalid section: missing : after section id",
+		"index.hi:4:1: invalid loop header: expected [for <vars> in <source>]",
+		"index.hi:6:37: missing ] in loop header",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(list), list)
+	}
+	for i, w := range want {
+		if got := list[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParseErrorHint(t *testing.T) {
+	src := "[for x in xs]\n  x.a\n    x.b\n"
+	_, _, err := ParseFile("a.hi", strings.NewReader(src))
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if got := list[0].Error(); got != "a.hi:3:5: unexpected indent" {
+		t.Fatalf("unexpected error %q", got)
+	}
+	if got, want := list[0].Hint(), "    x.b\n    ^"; got != want {
+		t.Fatalf("hint = %q, want %q", got, want)
+	}
+
+	// Col counts bytes; the caret goes under the character
+	_, _, err = ParseFile("a.hi", strings.NewReader("[for x in xs where x != \"—\" limit 0]\n  x\n"))
+	if !errors.As(err, &list) || len(list) != 1 {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	if got := list[0].Error(); got != `a.hi:1:37: invalid limit "0"` {
+		t.Fatalf("unexpected error %q", got)
+	}
+	if got, want := list[0].Hint(), "[for x in xs where x != \"—\" limit 0]\n"+strings.Repeat(" ", 34)+"^"; got != want {
+		t.Fatalf("hint = %q, want %q", got, want)
+	}
+}
+
+func TestParseIfElse(t *testing.T) {
+	src := `[for repo in repos]
+  [if repo.description]
+    repo.description
+
+  [else]
+    repo.name
+  repo.html_url
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := nodes[0].(Loop).Body
+	if len(body) != 2 {
+		t.Fatalf("expected if and field in loop body, got %#v", body)
+	}
+	n, ok := body[0].(If)
+	if !ok {
+		t.Fatalf("expected If, got %#v", body[0])
+	}
+	if len(n.Then) != 1 || len(n.Else) != 1 {
+		t.Fatalf("unexpected branches: %#v", n)
+	}
+	if f := n.Else[0].(Field); f.Path != "repo.name" {
+		t.Fatalf("unexpected else body: %#v", n.Else)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func parseTestFile(t *testing.T, path string) ([]Binding, []Node, error) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return ParseFile(path, f)
+}
+
+func TestParseInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.hi": "things = things.json\n{hero: Hi}\n[include partials/repos.hi]\n{things: Stuff}\n",
+		"partials/repos.hi": "repos = repos.json << https://example.com/repos\n" +
+			"{repos: Repos}\n[for repo in repos]\n  repo.name\n",
+	})
+	bindings, nodes, err := parseTestFile(t, filepath.Join(dir, "index.hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings) != 2 || bindings[1].Name != "repos" {
+		t.Fatalf("expected included binding, got %+v", bindings)
+	}
+	var ids []string
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case Section:
+			ids = append(ids, n.ID)
+		case Loop:
+			ids = append(ids, "for "+n.Source)
+		}
+	}
+	if got := strings.Join(ids, ","); got != "hero,repos,for repos,things" {
+		t.Fatalf("unexpected node order %s", got)
+	}
+}
+
+func TestParseIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.hi": "repos = repos.json\n[include a.hi]\n",
+		"a.hi":     "repos = other.json\n[for x xs]\n[include b.hi]\n",
+		"b.hi":     "[include a.hi]\n",
+	})
+	_, _, err := parseTestFile(t, filepath.Join(dir, "index.hi"))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "a.hi") + ":1:1: binding repos redeclared; previously declared at " + filepath.Join(dir, "index.hi") + ":1:1",
+		filepath.Join(dir, "a.hi") + ":2:1: invalid loop header: expected [for <vars> in <source>]",
+		filepath.Join(dir, "b.hi") + ":1:10: include cycle: a.hi -> b.hi -> a.hi",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), list)
+	}
+	for i, w := range want {
+		if got := list[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParseBlockSection(t *testing.T) {
+	src := `{about: Hi there.
+
+  # Background
+
+  - one
+  - two
+}
+{things: A list.}
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 sections, got %#v", nodes)
+	}
+	sec := nodes[0].(Section)
+	want := "Hi there.\n\n# Background\n\n- one\n- two"
+	if !sec.Block || sec.ID != "about" || sec.Text != want {
+		t.Fatalf("unexpected block section %#v", sec)
+	}
+	if nodes[1].(Section).Block {
+		t.Fatalf("one-line section parsed as block")
+	}
+}
+
+func TestParseLoopWindow(t *testing.T) {
+	_, nodes, err := Parse(strings.NewReader("[for repo in repos: stargazers_count, updated_at offset 6 limit 3]\n  repo.name\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop := nodes[0].(Loop)
+	if loop.Limit != 3 || loop.Offset != 6 || len(loop.Sort) != 2 || loop.Sort[1].Path != "updated_at" {
+		t.Fatalf("unexpected loop %+v", loop)
+	}
+	items := make([]interface{}, 10)
+	for i := range items {
+		items[i] = i
+	}
+	if got := loop.window(items); len(got) != 3 || got[0] != 6 {
+		t.Fatalf("unexpected window %v", got)
+	}
+	loop.Offset = 8
+	if got := loop.window(items); len(got) != 2 {
+		t.Fatalf("unexpected window %v", got)
+	}
+	if _, _, err := Parse(strings.NewReader("[for repo in repos limit 0]\n")); err == nil || err.Error() != "<input>:1:26: invalid limit \"0\"" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestParseLoopWhere(t *testing.T) {
+	src := `[for app in apps.results where kind == "software" and trackName != "a: b": currentVersionReleaseDate limit 2]` + "\n"
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop := nodes[0].(Loop)
+	if loop.Source != "apps.results" || loop.Where == nil || len(loop.Sort) != 1 || loop.Limit != 2 {
+		t.Fatalf("unexpected loop %+v", loop)
+	}
+	c := &context{}
+	items := []interface{}{
+		map[string]interface{}{"wrapperType": "artist"},
+		map[string]interface{}{"kind": "software", "trackName": "Vortex"},
+		map[string]interface{}{"kind": "software", "trackName": "a: b"},
+	}
+	if got := c.filter(loop, items, nil, false); len(got) != 1 || Resolve(got[0], "trackName") != "Vortex" {
+		t.Fatalf("unexpected filter result %v", got)
+	}
+}
+
+func TestParseIndentation(t *testing.T) {
+	for _, src := range []string{
+		"[for repo in repos]\n    repo.name\n    [if repo.fork]\n        repo.parent\n",
+		"[for repo in repos]\n\trepo.name\n\t[if repo.fork]\n\t\trepo.parent\n",
+	} {
+		_, nodes, err := Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		body := nodes[0].(Loop).Body
+		if len(body) != 2 || body[0].(Field).Path != "repo.name" || body[1].(If).Then[0].(Field).Path != "repo.parent" {
+			t.Fatalf("%q: unexpected body %+v", src, body)
+		}
+	}
+
+	src := "[for repo in repos]\n\trepo.name\n  repo.url\n \trepo.homepage\n"
+	_, _, err := Parse(strings.NewReader(src))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	want := []string{
+		"<input>:3:1: mixed tabs and spaces in indentation: block is indented with tabs",
+		"<input>:4:1: mixed tabs and spaces in indentation",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), list)
+	}
+	for i, w := range want {
+		if got := list[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParseIndentErrorsKeepParsing(t *testing.T) {
+	// each bad line skips only what is nested under it, so the
+	// second error in the block is still reported
+	src := "[for repo in repos]\n  repo.name\n\trepo.url\n  repo.description\n  [for x in]\n    x\n  repo.homepage\n"
+	_, _, err := Parse(strings.NewReader(src))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	want := []string{
+		"<input>:3:1: mixed tabs and spaces in indentation: block is indented with spaces",
+		"<input>:5:3: invalid loop header: expected [for <vars> in <source>]",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), list)
+	}
+	for i, w := range want {
+		if got := list[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestLoopGroupBy(t *testing.T) {
+	src := "[for kind, items in stuff where exists name group by kind: kind^]\n  kind\n  [for s in items: name]\n    s.name\n"
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop := nodes[0].(Loop)
+	if loop.GroupBy != "kind" || loop.Where == nil || len(loop.Sort) != 1 {
+		t.Fatalf("unexpected loop %+v", loop)
+	}
+	c := &context{bindings: map[string]interface{}{"stuff": []interface{}{
+		map[string]interface{}{"name": "a", "kind": "y"},
+		map[string]interface{}{"name": "b", "kind": "x"},
+		map[string]interface{}{"kind": "z"},
+		map[string]interface{}{"name": "c", "kind": "y"},
+	}}}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `<section class="section">` +
+		`<div class="box"><p>x</p><section class="section"><div class="box"><p>b</p></div></section></div>` +
+		`<div class="box"><p>y</p><section class="section"><div class="box"><p>c</p></div><div class="box"><p>a</p></div></section></div>` +
+		`</section>`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, _, err := Parse(strings.NewReader("[for items in stuff group by kind]\n")); err == nil || !strings.Contains(err.Error(), "group by needs two loop vars") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestParseTabInSlot(t *testing.T) {
+	_, _, err := Parse(strings.NewReader("{links tab slot=\"sidebar\": Links}\n"))
+	if want := "<input>:1:1: section links cannot be both a tab and in slot sidebar"; err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+	if _, _, err := Parse(strings.NewReader("{links tab slot=\"content\": Links}\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSectionAttrs(t *testing.T) {
+	src := `{about .is-wide .dark tab="About: me" hidden data-x=1: Hi}
+{notes element=aside:
+  Some *notes*.
+}
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	about := nodes[0].(Section)
+	if about.ID != "about" || about.Text != "Hi" || len(about.Classes) != 2 || about.Classes[1] != "dark" {
+		t.Fatalf("unexpected section %+v", about)
+	}
+	if v, ok := about.Attr("tab"); !ok || v != "About: me" {
+		t.Fatalf("tab = %q, %v", v, ok)
+	}
+	if v, ok := about.Attr("hidden"); !ok || v != "" {
+		t.Fatalf("hidden = %q, %v", v, ok)
+	}
+	if v, _ := about.Attr("data-x"); v != "1" {
+		t.Fatalf("data-x = %q", v)
+	}
+	if notes := nodes[1].(Section); !notes.Block || notes.Text != "Some *notes*." {
+		t.Fatalf("unexpected section %+v", notes)
+	}
+
+	_, _, err = Parse(strings.NewReader("{about .x =y: Hi}\n{notes element=\"a b\": Hi}\n"))
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	if got := list[0].Error(); got != `<input>:1:11: invalid section attribute "=y"` {
+		t.Errorf("unexpected error %q", got)
+	}
+	if got := list[1].Error(); got != `<input>:2:1: invalid element "a b" for section notes` {
+		t.Errorf("unexpected error %q", got)
+	}
+}
@@ -0,0 +1,204 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// LoopRenderer renders the items of a loop whose header says `as <name>`
+// for the name it was registered under.
+type LoopRenderer func(w io.Writer, lc *LoopContext) error
+
+// LoopContext is what a LoopRenderer gets: the loop and its items, after
+// where, sorting, limit and offset. For loops over objects and groups the
+// items are {key, value} entries.
+type LoopContext struct {
+	Loop  Loop
+	Items []interface{}
+
+	c       *context
+	vars    map[string]interface{}
+	entries bool
+}
+
+// Vars returns the vars the loop body sees for item i: the enclosing vars,
+// the loop vars and loop.
+func (lc *LoopContext) Vars(i int) map[string]interface{} {
+	return merge(lc.vars, lc.Loop.iterVars(lc.vars, lc.Items[i], lc.entries, i, len(lc.Items)))
+}
+
+// Body renders the loop body for item i.
+func (lc *LoopContext) Body(i int) (string, error) {
+	return lc.body(lc.Vars(i))
+}
+
+func (lc *LoopContext) body(vars map[string]interface{}) (string, error) {
+	var buf strings.Builder
+	err := lc.c.renderNodes(lc.Loop.Body, vars, &buf)
+	return buf.String(), err
+}
+
+// Group returns the key of the filter grid group the loop is directly in,
+// which the enclosing filter grid sets as loop.group. Card loops there put
+// their cards in the grid's cells instead of a grid of their own.
+func (lc *LoopContext) Group() (string, bool) {
+	loop, _ := lc.vars["loop"].(map[string]interface{})
+	group, ok := loop["group"].(string)
+	return group, ok
+}
+
+var renderers = map[string]LoopRenderer{}
+
+func init() {
+	// registered here since they render loop bodies, which can use renderers
+	RegisterRenderer("box", renderBoxes)
+	RegisterRenderer("filter_grid", renderFilterGrid)
+}
+
+// RegisterRenderer makes r available to loops as `as name`, ahead of any
+// card partial of that name. Renderers must be registered before rendering.
+func RegisterRenderer(name string, r LoopRenderer) {
+	renderers[name] = r
+}
+
+// present renders a loop's items the way its header asks. Without `as`, a
+// body with its own markup renders as is; otherwise the items are guessed
+// at from their fields, or shown in boxes.
+func (c *context) present(l Loop, items []interface{}, vars map[string]interface{}, entries bool, buf *strings.Builder) error {
+	name := l.As
+	if name == "" {
+		if hasMarkup(l.Body) {
+			return c.renderBodies(l, items, vars, entries, buf)
+		}
+		name = c.guessPresentation(l, items)
+	}
+	lc := &LoopContext{Loop: l, Items: items, c: c, vars: vars, entries: entries}
+	if r, ok := renderers[name]; ok {
+		return r(buf, lc)
+	}
+	return c.renderCards(name, lc, buf)
+}
+
+// guessPresentation is the deprecated fallback for loops without `as`: it
+// picks a default card for apps, things and repos, and the filter grid for
+// things grouped by a field, by looking at the fields of the first item.
+// Anything else is shown in boxes.
+func (c *context) guessPresentation(l Loop, items []interface{}) string {
+	if len(items) == 0 {
+		return "box"
+	}
+	first := items[0]
+	if l.GroupBy != "" {
+		first = first.(map[string]interface{})["value"].([]interface{})[0]
+	}
+	name := "box"
+	switch {
+	case l.GroupBy != "":
+		if hasFields(first, "title", "url", "category", "date_published") {
+			name = "filter_grid"
+		}
+	case hasFields(first, "trackName", "trackViewUrl", "genres"):
+		name = "app_card"
+	case hasFields(first, "title", "url", "category", "date_published"):
+		name = "thing_card"
+	case hasFields(first, "name", "html_url", "stargazers_count", "updated_at"):
+		name = "repo_card"
+	}
+	if name != "box" {
+		c.warnf(l.Pos(), "guessed %q from the fields of %s, which is deprecated; add `as %s` to the loop header", name, l.Source, name)
+	}
+	return name
+}
+
+// renderBoxes renders each item's body in a box.
+func renderBoxes(w io.Writer, lc *LoopContext) error {
+	io.WriteString(w, `<section class="section">`)
+	for i := range lc.Items {
+		body, err := lc.Body(i)
+		if err != nil {
+			return err
+		}
+		io.WriteString(w, `<div class="box">`+body+`</div>`)
+	}
+	io.WriteString(w, `</section>`)
+	return nil
+}
+
+// filterGridTmpl holds the markup of a filter grid that carries category
+// names, so that html/template escapes them for the JS and attributes they
+// land in.
+var filterGridTmpl = template.Must(template.New("filter_grid").Parse(`{{define "buttons"}}` +
+	`<div class="category-filter-wrapper">` +
+	`<div class="category-filter-scroll">` +
+	`<div class="level is-mobile category-filter-level">` +
+	`<div class="level-item">` +
+	`<div class="buttons has-addons category-filter-buttons">` +
+	`<button class="button is-info is-selected" onclick="filterThings('all')">All</button>` +
+	`{{range .}}<button class="button" onclick="filterThings('{{.Key}}')">{{.Label}}</button>{{end}}` +
+	`</div></div></div></div></div>` +
+	`{{end}}` +
+	`{{define "cell"}}<div class="cell thing-item" data-category="{{.}}">{{end}}`))
+
+// filterButton is a category filter button: Key goes to filterThings and
+// Label on the button.
+type filterButton struct {
+	Key, Label string
+}
+
+// renderFilterGrid renders the groups of a group by loop as one grid with
+// a filter button per group; the card loop inside the body fills in the
+// cells, tagged with their group, which it finds in loop.group.
+func renderFilterGrid(w io.Writer, lc *LoopContext) error {
+	buttons := make([]filterButton, len(lc.Items))
+	for i, g := range lc.Items {
+		group, ok := g.(map[string]interface{})
+		if !ok || !lc.entries {
+			return fmt.Errorf("%s: filter_grid needs a group by loop", lc.Loop.Pos())
+		}
+		category := fmt.Sprint(group["key"])
+		buttons[i] = filterButton{Key: category, Label: category}
+		if len(category) > 0 {
+			buttons[i].Label = strings.ToUpper(category[:1]) + category[1:]
+		}
+	}
+	if err := filterGridTmpl.ExecuteTemplate(w, "buttons", buttons); err != nil {
+		return err
+	}
+
+	io.WriteString(w, `<section class="section">`)
+	io.WriteString(w, `<div class="container">`)
+	io.WriteString(w, `<div class="grid is-col-min-16" id="things-grid">`)
+	for i := range lc.Items {
+		vars := lc.Vars(i)
+		if loop, ok := vars["loop"].(map[string]interface{}); ok {
+			loop["group"] = buttons[i].Key
+		}
+		body, err := lc.body(vars)
+		if err != nil {
+			return err
+		}
+		io.WriteString(w, body)
+	}
+	io.WriteString(w, `</div>`)
+	io.WriteString(w, `</div>`)
+	io.WriteString(w, `</section>`)
+	return nil
+}
@@ -0,0 +1,131 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterRenderer(t *testing.T) {
+	RegisterRenderer("test_list", func(w io.Writer, lc *LoopContext) error {
+		io.WriteString(w, "<ul>")
+		for i := range lc.Items {
+			body, err := lc.Body(i)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "<li>%v:%s</li>", lc.Vars(i)["loop"].(map[string]interface{})["index1"], body)
+		}
+		io.WriteString(w, "</ul>")
+		return nil
+	})
+	defer delete(renderers, "test_list")
+	out := renderCardTest(t, "", "[for x in xs as test_list]\n  x\n", map[string]interface{}{"xs": []interface{}{"a", "b"}})
+	if want := "<ul><li>1:<p>a</p></li><li>2:<p>b</p></li></ul>"; out != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+}
+
+func TestGuessPresentation(t *testing.T) {
+	things := []interface{}{map[string]interface{}{
+		"title": "Pen", "url": "https://example.com", "category": "office", "date_published": "2025-01-04",
+		// fields apps have too, which fool the guess
+		"genres": []interface{}{"Tools"}, "trackName": "Pen", "trackViewUrl": "https://example.com",
+	}}
+	for _, tt := range []struct {
+		src, card, warning string
+	}{
+		{"[for thing in things as thing_card]\n  thing.title\n", `<div class="cell thing-item">`, ""},
+		{"[for thing in things]\n  thing.title\n", `<div class="cell"><div class="card">`, "<input>:1:1: warning: guessed \"app_card\" from the fields of things, which is deprecated; add `as app_card` to the loop header\n"},
+		{"[for thing in things as box]\n  thing.title\n", `<div class="box"><p>Pen</p></div>`, ""},
+	} {
+		_, nodes, err := Parse(strings.NewReader(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		set, err := loadCards("../templates/cards")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stderr strings.Builder
+		c := &context{bindings: map[string]interface{}{"things": things}, cards: set, stderr: &stderr}
+		var buf strings.Builder
+		if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.card) {
+			t.Errorf("%q: output is missing %q:\n%s", tt.src, tt.card, buf.String())
+		}
+		if stderr.String() != tt.warning {
+			t.Errorf("%q: warnings = %q, want %q", tt.src, stderr.String(), tt.warning)
+		}
+	}
+}
+
+func TestFilterGridGroup(t *testing.T) {
+	RegisterRenderer("test_group", func(w io.Writer, lc *LoopContext) error {
+		group, ok := lc.Group()
+		fmt.Fprintf(w, "<i>%s %v</i>", group, ok)
+		return nil
+	})
+	defer delete(renderers, "test_group")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "name_card.html"), []byte("<b>{{.item.name}}</b>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := `[for kind, items in stuff group by kind as filter_grid]
+  [for s in items as name_card]
+  [for s in items as test_group]
+  [for s in items as box]
+    [for p in s.parts as name_card]
+`
+	stuff := []interface{}{map[string]interface{}{"kind": "a", "name": "x", "parts": []interface{}{map[string]interface{}{"name": "y"}}}}
+	out := renderCardTest(t, dir, src, map[string]interface{}{"stuff": stuff})
+	for _, want := range []string{
+		`<div class="cell thing-item" data-category="a"><b>x</b></div>`,
+		`<i>a true</i>`,
+		// the card loop in the box is not directly in the grid
+		`<div class="box"><section class="section"><div class="container"><div class="grid is-col-min-16"><div class="cell"><b>y</b></div>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "data-category") != 1 {
+		t.Errorf("group leaked into a nested loop:\n%s", out)
+	}
+}
+
+func TestFilterGridNeedsGroupBy(t *testing.T) {
+	_, _, err := Parse(strings.NewReader("[for x in xs as filter_grid]\n  x\n"))
+	if err == nil || !strings.Contains(err.Error(), "<input>:1:17: filter_grid needs a group by loop") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// a loop built without the parser gets an error, not a panic
+	c := &context{bindings: map[string]interface{}{"xs": []interface{}{"a", "b"}}, stderr: &strings.Builder{}}
+	l := Loop{Vars: []string{"x"}, Source: "xs", As: "filter_grid"}
+	var buf strings.Builder
+	if err := c.renderLoop(l, map[string]interface{}{}, &buf); err == nil || !strings.Contains(err.Error(), "filter_grid needs a group by loop") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
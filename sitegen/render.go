@@ -19,48 +19,101 @@ package sitegen
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/yuin/goldmark"
 )
 
-// RenderOptions holds CLI options.
+// RenderOptions holds CLI options. Cards is the directory of html/template
+// partials that loops render their items through. Vars are substituted for ${name} in
+// binding targets and URLs, ahead of the environment.
+//
+// Render reads Input and writes Out. RenderSite instead renders every page
+// under Pages, and Out is the output directory.
 type RenderOptions struct {
 	Input   string
 	Out     string
+	Pages   string // directory of .hi pages, for RenderSite
 	DataDir string
 	Layout  string
+	Cards   string // directory of card partials
+	Vars    map[string]string
 }
 
 // Render performs full render pipeline.
 func Render(opts RenderOptions) error {
-	in, err := os.ReadFile(opts.Input)
+	p, err := readPage(opts.Input)
 	if err != nil {
 		return err
 	}
-	bindings, nodes, err := Parse(bytes.NewReader(in))
+	ctx, err := newContext(opts)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(opts.DataDir, 0o755); err != nil {
+	if err := ctx.loadBindings(p.bindings, opts); err != nil {
+		return err
+	}
+	if err := ctx.renderPage(p, opts.Out, opts.Layout, nil); err != nil {
 		return err
 	}
+	return ctx.finish(filepath.Dir(opts.Out), opts.DataDir)
+}
+
+// newContext sets up the state shared by every page of a render: the
+// fetcher and its caches, the bindings and the card partials.
+func newContext(opts RenderOptions) (*context, error) {
+	if err := os.MkdirAll(opts.DataDir, 0o755); err != nil {
+		return nil, err
+	}
 	fetcher := NewFetcher(opts.DataDir)
 	fetcher.LoadETags()
 	ctx := &context{
 		bindings: make(map[string]interface{}),
 		lazy:     make(map[string]*lazyBinding),
-		fetcher:  fetcher,
+		declared: make(map[string]Binding),
+		warned:   make(map[string]bool),
+		rawTmpls: make(map[string]*template.Template),
+		// shared by the pages, which each render with a copy of ctx
+		optionalSlots: make(map[Pos]*optionalSlot),
+		fetcher:       fetcher,
+		cardsDir:      opts.Cards,
+	}
+	var err error
+	if ctx.cards, err = loadCards(opts.Cards); err != nil {
+		return nil, err
 	}
-	// resolve bindings
+	return ctx, nil
+}
+
+// loadBindings resolves bindings into c. A binding already declared by
+// another page is loaded once; declaring it differently is an error.
+func (c *context) loadBindings(bindings []Binding, opts RenderOptions) error {
+	var err error
 	for _, b := range bindings {
+		if prev, ok := c.declared[b.Name]; ok {
+			if !sameBinding(prev, b) {
+				return fmt.Errorf("%s: binding %s redeclared; previously declared at %s", b.Pos(), b.Name, prev.Pos())
+			}
+			continue
+		}
+		c.declared[b.Name] = b
+		if b.Target, err = expandVars(b.Target, opts.Vars); err == nil {
+			b.URL, err = expandVars(b.URL, opts.Vars)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.Pos(), err)
+		}
 		if b.URL != "" && !b.Lazy {
-			body, err := fetcher.Fetch(b.Target, b.URL)
+			body, err := c.fetcher.Fetch(b.Target, b.URL)
 			if err != nil {
 				// if file exists use cache
 				path := filepath.Join(opts.DataDir, b.Target)
@@ -72,17 +125,30 @@ func Render(opts RenderOptions) error {
 			if err := json.Unmarshal(body, &v); err != nil {
 				return err
 			}
-			ctx.bindings[b.Name] = v
+			if err := c.bind(b, v); err != nil {
+				return err
+			}
 			continue
 		}
 		if b.URL != "" && b.Lazy {
-			lb := &lazyBinding{Target: b.Target, Template: b.URL, Data: make(map[string]interface{}), Fetched: make(map[string]bool)}
+			lb := &lazyBinding{Target: b.Target, Template: b.URL, Data: make(map[string]interface{}), Fetched: make(map[string]bool), Pos: b.Pos(), Transforms: b.Transforms}
 			// load cache
 			path := filepath.Join(opts.DataDir, b.Target)
 			if data, err := os.ReadFile(path); err == nil {
 				json.Unmarshal(data, &lb.Data)
 			}
-			ctx.lazy[b.Name] = lb
+			c.lazy[b.Name] = lb
+			continue
+		}
+		if b.Glob {
+			// patterns are relative to the .hi file that declares them
+			v, err := loadGlob(filepath.Dir(b.Pos().File), b.Target)
+			if err != nil {
+				return fmt.Errorf("%s: %w", b.Pos(), err)
+			}
+			if err := c.bind(b, v); err != nil {
+				return err
+			}
 			continue
 		}
 		if b.Manual {
@@ -95,67 +161,244 @@ func Render(opts RenderOptions) error {
 			if err := json.Unmarshal(body, &v); err != nil {
 				return err
 			}
-			ctx.bindings[b.Name] = v
+			if err := c.bind(b, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sameBinding reports whether a and b, declared in different pages, load
+// the same value.
+func sameBinding(a, b Binding) bool {
+	if a.Target != b.Target || a.URL != b.URL || a.Lazy != b.Lazy || a.Manual != b.Manual || a.Glob != b.Glob {
+		return false
+	}
+	if a.Glob && filepath.Dir(a.Pos().File) != filepath.Dir(b.Pos().File) {
+		return false
+	}
+	if len(a.Transforms) != len(b.Transforms) {
+		return false
+	}
+	for i, t := range a.Transforms {
+		u := b.Transforms[i]
+		if t.Path != u.Path || (t.Where == nil) != (u.Where == nil) || t.Where != nil && ExprString(t.Where) != ExprString(u.Where) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderPage renders p into its layout and writes it to out. The layout is
+// the page's own, if its front matter sets one, or else layout. With nav
+// set, the page sees it as the nav binding.
+func (c *context) renderPage(p *page, out, layout string, nav []interface{}) error {
+	// tabs, macros and slots belong to the page; bindings and caches are
+	// shared
+	pc := *c
+	pc.tabs = tabSections(p.nodes)
+	pc.macros = macroDefs(p.nodes)
+	pc.slots = nil
+	if nav != nil {
+		pc.bindings = make(map[string]interface{}, len(c.bindings)+1)
+		for k, v := range c.bindings {
+			pc.bindings[k] = v
 		}
+		pc.bindings["nav"] = nav
 	}
 	var buf strings.Builder
-	if err := ctx.renderNodes(nodes, make(map[string]interface{}), &buf); err != nil {
+	if err := pc.renderNodes(p.nodes, make(map[string]interface{}), &buf); err != nil {
 		return err
 	}
+	if p.front.Layout != "" {
+		// relative to the page, like glob patterns
+		layout = filepath.Join(filepath.Dir(p.path), p.front.Layout)
+	}
 	// load layout
-	layout, err := os.ReadFile(opts.Layout)
+	tmpl, err := os.ReadFile(layout)
 	if err != nil {
 		return err
 	}
-	bodyHTML := buf.String()
-	outHTML := strings.Replace(string(layout), "<!--CONTENT-->", bodyHTML, 1)
+	outHTML := pc.fillLayout(layout, string(tmpl), buf.String(), p.nodes)
+	outHTML = strings.Replace(outHTML, "<!--TITLE-->", htmlEscape(p.title()), 1)
 	// last updated: use now with readable format
 	outHTML = strings.Replace(outHTML, "<!--LAST_UPDATED-->", time.Now().Format("January 2, 2006"), 1)
-	if err := os.MkdirAll(filepath.Dir(opts.Out), 0o755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
 		return err
 	}
-	if err := os.WriteFile(opts.Out, []byte(outHTML), 0o644); err != nil {
-		return err
-	}
-	
+	return os.WriteFile(out, []byte(outHTML), 0o644)
+}
+
+// finish writes the files that belong to the whole site to outDir and
+// saves the fetch caches.
+func (c *context) finish(outDir, dataDir string) error {
+	c.warnUnfilledSlots()
+
 	// Generate dynamic CSS with timestamp-based color
-	if err := generateCSS(filepath.Dir(opts.Out)); err != nil {
+	if err := generateCSS(outDir); err != nil {
 		return fmt.Errorf("failed to generate CSS: %w", err)
 	}
 	
 	// Generate individual thing pages
-	if err := ctx.generateThingPages(filepath.Dir(opts.Out)); err != nil {
+	if err := c.generateThingPages(outDir); err != nil {
 		return fmt.Errorf("failed to generate thing pages: %w", err)
 	}
 	
-	fetcher.SaveETags()
+	c.fetcher.SaveETags()
 	// save lazy caches
-	for _, lb := range ctx.lazy {
-		path := filepath.Join(opts.DataDir, lb.Target)
+	for _, lb := range c.lazy {
+		path := filepath.Join(dataDir, lb.Target)
 		b, _ := json.MarshalIndent(lb.Data, "", "  ")
 		os.WriteFile(path, b, 0o644)
 	}
 	return nil
 }
 
+// expandVars replaces each ${name} in s with its value from vars or, failing
+// that, the environment.
+func expandVars(s string, vars map[string]string) (string, error) {
+	var out strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			out.WriteString(s)
+			return out.String(), nil
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("unterminated ${ in %q", s)
+		}
+		name := s[i+2 : i+j]
+		v, ok := vars[name]
+		if !ok {
+			v, ok = os.LookupEnv(name)
+		}
+		if !ok {
+			return "", fmt.Errorf("${%s} is not set; set %s in the environment or pass -var %s=<value>", name, name, name)
+		}
+		out.WriteString(s[:i])
+		out.WriteString(v)
+		s = s[i+j+1:]
+	}
+}
+
 type context struct {
 	bindings map[string]interface{}
 	lazy     map[string]*lazyBinding
+	declared map[string]Binding // bindings loaded so far, by name
 	fetcher  *Fetcher
+	stderr   io.Writer       // where warnings go; os.Stderr if nil
+	warned   map[string]bool // warnings already printed this run
+	tabs     []Section       // sections shown in the tab bar
+
+	tabBarDone bool // the page's tab bar is written
+
+	macros    map[string]Define
+	expanding map[string]bool // macros being rendered, to catch recursion
+
+	cards    *template.Template // card partials, by name
+	cardsDir string
+
+	slots         map[string]*strings.Builder // output for named layout slots
+	optionalSlots map[Pos]*optionalSlot       // slot markers inside IF SLOT blocks
+
+	rawTmpls map[string]*template.Template // raw HTML lines, parsed
+}
+
+// warnf prints a warning pointing at pos in the .hi source, once per run.
+func (c *context) warnf(pos Pos, format string, args ...interface{}) {
+	msg := fmt.Sprintf("%s: warning: %s", pos, fmt.Sprintf(format, args...))
+	if c.warned == nil {
+		c.warned = make(map[string]bool)
+	}
+	if c.warned[msg] {
+		return
+	}
+	c.warned[msg] = true
+	w := c.stderr
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintln(w, msg)
+}
+
+// bind runs v through the binding's transforms and binds the result. Any
+// cache file keeps the untransformed value.
+func (c *context) bind(b Binding, v interface{}) error {
+	v, err := applyTransforms(v, b.Transforms)
+	if err != nil {
+		return fmt.Errorf("%s: binding %s: %w", b.Pos(), b.Name, err)
+	}
+	c.bindings[b.Name] = v
+	return nil
+}
+
+// applyTransforms applies a binding's pipeline to v. Paths in a where step
+// resolve against each item.
+func applyTransforms(v interface{}, ts []Transform) (interface{}, error) {
+	for _, t := range ts {
+		if t.Where == nil {
+			v = Resolve(v, t.Path)
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("where %s: value is not an array", ExprString(t.Where))
+		}
+		kept := make([]interface{}, 0, len(arr))
+		for _, it := range arr {
+			if truthy(evalExpr(t.Where, func(path string) interface{} { return Resolve(it, path) })) {
+				kept = append(kept, it)
+			}
+		}
+		v = kept
+	}
+	return v, nil
 }
 
 type lazyBinding struct {
-	Target   string
-	Template string
-	Data     map[string]interface{}
-	Fetched  map[string]bool
+	Target     string
+	Template   string
+	Data       map[string]interface{}
+	Fetched    map[string]bool
+	Pos        Pos
+	Transforms []Transform
+}
+
+// lazyValue applies the binding's transforms to a cached or fetched value.
+func (c *context) lazyValue(lb *lazyBinding, v interface{}) interface{} {
+	v, err := applyTransforms(v, lb.Transforms)
+	if err != nil {
+		c.warnf(lb.Pos, "%v", err)
+		return nil
+	}
+	return v
 }
 
 func (c *context) renderNodes(nodes []Node, vars map[string]interface{}, buf *strings.Builder) error {
-	var heroRendered bool
-	var tabsAdded bool
+	var tabOpen bool
+	var hidden bool
+	// out is buf, or the layout slot the current section goes to
+	out := buf
 	
 	for _, n := range nodes {
+		if sec, ok := n.(Section); ok {
+			// a section ends the content of the tab before it
+			if tabOpen {
+				out.WriteString(`</div>`)
+				tabOpen = false
+			}
+			// a hidden section hides everything up to the next section
+			_, hidden = sec.Attr("hidden")
+			out = buf
+			if name, _ := sec.Attr("slot"); name != "" && name != "content" {
+				out = c.slot(name)
+			}
+		}
+		if hidden {
+			continue
+		}
 		switch t := n.(type) {
 		case Section:
 			md := goldmark.New()
@@ -163,295 +406,634 @@ func (c *context) renderNodes(nodes []Node, vars map[string]interface{}, buf *st
 			if err := md.Convert([]byte(t.Text), &h); err != nil {
 				return err
 			}
+			// One-line sections are titles, so drop the paragraph wrapper;
+			// block sections keep the full markdown output.
+			titleText := strings.TrimPrefix(strings.TrimSuffix(h.String(), "</p>\n"), "<p>")
 			
-			// Special handling for hero section
-			if t.ID == "hero" {
-				buf.WriteString(fmt.Sprintf(`<section class="hero is-dark is-medium">
-  <div class="hero-body">
-    <div class="container">
-      <h1 class="title is-1 has-text-white">%s</h1>
-    </div>
-  </div>
-</section>`, strings.TrimPrefix(strings.TrimSuffix(h.String(), "</p>\n"), "<p>")))
-				heroRendered = true
-			} else {
-				// Add tabs after hero but before other sections
-				if heroRendered && !tabsAdded {
-					buf.WriteString(`
-<div class="container">
-  <div class="tabs is-centered is-large is-boxed has-text-weight-semibold is-family-code is-lowercase">
-    <ul>
-      <li class="is-active" data-tab="things">
-        <a>
-          <span>Things</span>
-        </a>
-      </li>
-      <li data-tab="apps">
-        <a>
-          <span>Apps</span>
-        </a>
-      </li>
-      <li data-tab="repos">
-        <a>
-          <span>Repos</span>
-        </a>
-      </li>
-    </ul>
-  </div>
-</div>`)
-					tabsAdded = true
+			// In a layout slot the layout supplies the markup around the
+			// section, so only its title or content is written
+			if out != buf {
+				classes := ""
+				for _, class := range t.Classes {
+					classes += " " + htmlEscape(class)
 				}
-				
-				// Check if this section should be wrapped in a tab content div
-				if t.ID == "things" || t.ID == "apps" || t.ID == "repos" {
-					titleText := strings.TrimPrefix(strings.TrimSuffix(h.String(), "</p>\n"), "<p>")
-					buf.WriteString(fmt.Sprintf(`<div id="%s-content" class="tab-content">
-<section class="section" id="%s">
+				if t.Block {
+					out.WriteString(`<div class="content` + classes + `">` + h.String() + `</div>`)
+				} else {
+					out.WriteString(`<h1 class="title` + classes + `">` + titleText + `</h1>`)
+				}
+				continue
+			}
+			
+			// The tab bar goes before the first tab
+			_, isTab := t.Attr("tab")
+			if isTab && !c.tabBarDone {
+				out.WriteString(tabBar(c.tabs))
+				c.tabBarDone = true
+			}
+			
+			heading := `<h2 class="subtitle has-text-weight-semibold">` + titleText + `</h2>`
+			if t.Block {
+				heading = `<div class="content">` + h.String() + `</div>`
+			}
+			
+			open, el := sectionTag(t)
+			// Sections marked as tabs are wrapped in a tab content div,
+			// closed at the next section
+			if isTab {
+				tabOpen = true
+				out.WriteString(fmt.Sprintf(`<div id="%s-content" class="tab-content">
+%s
   <div class="container">
-    <h2 class="subtitle has-text-weight-semibold">%s</h2>
+    %s
   </div>
-</section>`, t.ID, t.ID, titleText))
-				} else {
-					// Regular section rendering for non-tab sections
-					titleText := strings.TrimPrefix(strings.TrimSuffix(h.String(), "</p>\n"), "<p>")
-					buf.WriteString(fmt.Sprintf(`<section class="section" id="%s">
+</%s>`, htmlEscape(t.ID), open, heading, el))
+			} else {
+				out.WriteString(fmt.Sprintf(`%s
   <div class="container">
-    <h2 class="subtitle has-text-weight-semibold">%s</h2>
+    %s
   </div>
-</section>`, t.ID, titleText))
-				}
+</%s>`, open, heading, el))
 			}
 		case Field:
-			v := c.resolvePath(t.Path, vars)
-			buf.WriteString(fmt.Sprintf("<p>%s</p>", htmlEscape(fmt.Sprint(v))))
+			raw, found := c.lookupPath(t.Path, vars)
+			if !found {
+				c.warnf(t.Pos(), "missing field %s", t.Path)
+			}
+			v, err := applyFilters(raw, t.Filters)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", t.Pos(), t.Path, err)
+			}
+			out.WriteString(fieldHTML(v, t.Filters))
 		case Loop:
-			if err := c.renderLoop(t, vars, buf); err != nil {
+			w := out
+			if t.Slot != "" && t.Slot != "content" {
+				w = c.slot(t.Slot)
+			}
+			if err := c.renderLoop(t, vars, w); err != nil {
+				return err
+			}
+		case Raw:
+			line, err := c.interpolate(t, vars)
+			if err != nil {
+				return err
+			}
+			out.WriteString(line)
+			out.WriteString("\n")
+		case Define:
+			// rendered where it is used
+		case Use:
+			if err := c.renderUse(t, vars, out); err != nil {
+				return err
+			}
+		case If:
+			branch := t.Else
+			if truthy(evalExpr(t.Cond, func(path string) interface{} { return c.resolvePath(path, vars) })) {
+				branch = t.Then
+			}
+			if err := c.renderNodes(branch, vars, out); err != nil {
 				return err
 			}
 		}
 	}
+	if tabOpen {
+		out.WriteString(`</div>`)
+	}
 	return nil
 }
 
+// slot returns the buffer collecting the output for a named layout slot.
+func (c *context) slot(name string) *strings.Builder {
+	if c.slots == nil {
+		c.slots = make(map[string]*strings.Builder)
+	}
+	if c.slots[name] == nil {
+		c.slots[name] = &strings.Builder{}
+	}
+	return c.slots[name]
+}
+
+var (
+	slotRe   = regexp.MustCompile(`<!--SLOT:([A-Za-z0-9_-]+)-->`)
+	ifSlotRe = regexp.MustCompile(`<!--IF SLOT:([A-Za-z0-9_-]+)-->\n?`)
+)
+
+// fillLayout puts content in place of <!--CONTENT--> and the named slots in
+// place of their <!--SLOT:name--> markers. Markers for slots with no output
+// are removed, and so is the markup between <!--IF SLOT:name--> and
+// <!--END SLOT:name-->. It warns about slots in the layout that nothing in
+// the .hi file targets and about targeted slots the layout doesn't have.
+// A slot inside its own IF SLOT block may be left out of some pages, so it
+// is only noted here; finish warns if no page of the run fills it.
+func (c *context) fillLayout(file, layout, content string, nodes []Node) string {
+	targets := make(map[string]Pos)
+	var names []string
+	InspectAll(nodes, func(n Node) bool {
+		name := ""
+		switch t := n.(type) {
+		case Section:
+			name, _ = t.Attr("slot")
+		case Loop:
+			name = t.Slot
+		}
+		if _, seen := targets[name]; name != "" && name != "content" && !seen {
+			targets[name] = n.Pos()
+			names = append(names, name)
+		}
+		return true
+	})
+	inLayout := make(map[string]bool)
+	for _, m := range slotRe.FindAllStringSubmatchIndex(layout, -1) {
+		name := layout[m[2]:m[3]]
+		inLayout[name] = true
+		// a slot wrapped in its own IF SLOT block is optional
+		before := layout[:m[0]]
+		optional := strings.LastIndex(before, "<!--IF SLOT:"+name+"-->") > strings.LastIndex(before, "<!--END SLOT:"+name+"-->")
+		line := strings.Count(layout[:m[0]], "\n") + 1
+		col := m[0] - strings.LastIndex(layout[:m[0]], "\n")
+		pos := Pos{File: file, Line: line, Col: col}
+		_, filled := targets[name]
+		switch {
+		case optional:
+			if c.optionalSlots == nil {
+				c.optionalSlots = make(map[Pos]*optionalSlot)
+			}
+			if c.optionalSlots[pos] == nil {
+				c.optionalSlots[pos] = &optionalSlot{name: name}
+			}
+			c.optionalSlots[pos].filled = c.optionalSlots[pos].filled || filled
+		case !filled:
+			c.warnf(pos, "nothing fills slot %s", name)
+		}
+	}
+	for _, m := range ifSlotRe.FindAllStringSubmatchIndex(layout, -1) {
+		name := layout[m[2]:m[3]]
+		if !strings.Contains(layout[m[1]:], "<!--END SLOT:"+name+"-->") {
+			line := strings.Count(layout[:m[0]], "\n") + 1
+			col := m[0] - strings.LastIndex(layout[:m[0]], "\n")
+			c.warnf(Pos{File: file, Line: line, Col: col}, "<!--IF SLOT:%s--> has no <!--END SLOT:%s-->", name, name)
+		}
+	}
+	for _, name := range names {
+		if !inLayout[name] {
+			c.warnf(targets[name], "layout %s has no <!--SLOT:%s-->; its content is dropped", file, name)
+		}
+	}
+	out := c.optionalBlocks(layout)
+	out = slotRe.ReplaceAllStringFunc(out, func(m string) string {
+		if s := c.slots[slotRe.FindStringSubmatch(m)[1]]; s != nil {
+			return s.String()
+		}
+		return ""
+	})
+	return strings.Replace(out, "<!--CONTENT-->", content, 1)
+}
+
+// optionalSlot is a slot marker inside an IF SLOT block, and whether any page
+// rendered with its layout fills it.
+type optionalSlot struct {
+	name   string
+	filled bool
+}
+
+// warnUnfilledSlots warns about the slots inside IF SLOT blocks that no page
+// rendered so far fills.
+func (c *context) warnUnfilledSlots() {
+	var unfilled []Pos
+	for pos, slot := range c.optionalSlots {
+		if !slot.filled {
+			unfilled = append(unfilled, pos)
+		}
+	}
+	sort.Slice(unfilled, func(i, j int) bool {
+		a, b := unfilled[i], unfilled[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	for _, pos := range unfilled {
+		c.warnf(pos, "nothing fills slot %s on any page", c.optionalSlots[pos].name)
+	}
+}
+
+// optionalBlocks keeps the markup between <!--IF SLOT:name--> and
+// <!--END SLOT:name--> if the slot has output and drops it if not. A
+// newline right after a marker goes with it.
+func (c *context) optionalBlocks(layout string) string {
+	var b strings.Builder
+	for {
+		m := ifSlotRe.FindStringSubmatchIndex(layout)
+		if m == nil {
+			b.WriteString(layout)
+			return b.String()
+		}
+		name := layout[m[2]:m[3]]
+		b.WriteString(layout[:m[0]])
+		end := "<!--END SLOT:" + name + "-->"
+		i := strings.Index(layout[m[1]:], end)
+		if i < 0 {
+			// fillLayout has warned about it
+			layout = layout[m[1]:]
+			continue
+		}
+		inner := layout[m[1] : m[1]+i]
+		rest := strings.TrimPrefix(layout[m[1]+i+len(end):], "\n")
+		if s := c.slots[name]; s != nil && s.Len() > 0 {
+			// blocks can nest, so the kept markup is scanned again
+			layout = inner + rest
+		} else {
+			layout = rest
+		}
+	}
+}
+
+var placeholderRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_.]*)\}`)
+
+// interpolate fills the {path} placeholders of a raw HTML line. The line is
+// run through html/template, so each value is escaped for where it lands:
+// text, an attribute, a URL or inline JS. HTML values, like rendered
+// Markdown, are not escaped as text.
+func (c *context) interpolate(r Raw, vars map[string]interface{}) (string, error) {
+	matches := placeholderRe.FindAllStringSubmatch(r.Text, -1)
+	if matches == nil {
+		return r.Text, nil
+	}
+	t, err := c.rawTemplate(r.Text)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", r.Pos(), err)
+	}
+	vals := make([]interface{}, len(matches))
+	for i, m := range matches {
+		v, found := c.lookupPath(m[1], vars)
+		if !found {
+			c.warnf(r.Pos(), "missing field %s", m[1])
+		}
+		switch v := v.(type) {
+		case nil:
+			vals[i] = ""
+		case HTML:
+			vals[i] = template.HTML(v)
+		default:
+			vals[i] = v
+		}
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vals); err != nil {
+		var terr *template.Error
+		if errors.As(err, &terr) && terr.ErrorCode == template.ErrEndContext {
+			return "", fmt.Errorf("%s: raw HTML with placeholders must not end inside a tag, script or style, so the values can be escaped for it", r.Pos())
+		}
+		return "", fmt.Errorf("%s: %w", r.Pos(), err)
+	}
+	return b.String(), nil
+}
+
+// rawTemplate returns the html/template for a raw HTML line, with its nth
+// placeholder turned into an action printing the nth value. Templates are
+// kept for the rest of the run.
+func (c *context) rawTemplate(line string) (*template.Template, error) {
+	if t, ok := c.rawTmpls[line]; ok {
+		return t, nil
+	}
+	// delimiters that can't appear in a .hi line, so any {{ in the line is
+	// left alone
+	n := 0
+	text := placeholderRe.ReplaceAllStringFunc(line, func(string) string {
+		n++
+		return fmt.Sprintf("\x00index . %d\x01", n-1)
+	})
+	t, err := template.New("raw").Delims("\x00", "\x01").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if c.rawTmpls == nil {
+		c.rawTmpls = make(map[string]*template.Template)
+	}
+	c.rawTmpls[line] = t
+	return t, nil
+}
+
+// macroDefs collects the macros defined anywhere in nodes.
+func macroDefs(nodes []Node) map[string]Define {
+	defs := make(map[string]Define)
+	InspectAll(nodes, func(n Node) bool {
+		if d, ok := n.(Define); ok {
+			defs[d.Name] = d
+		}
+		return true
+	})
+	return defs
+}
+
+// renderUse renders a macro's body with its params bound to the use's
+// arguments. The body sees its params and the bindings, not the caller's
+// loop vars.
+func (c *context) renderUse(u Use, vars map[string]interface{}, buf *strings.Builder) error {
+	d, ok := c.macros[u.Name]
+	if !ok {
+		return fmt.Errorf("%s: undefined macro %s", u.Pos(), u.Name)
+	}
+	if c.expanding[u.Name] {
+		return fmt.Errorf("%s: macro %s uses itself", u.Pos(), u.Name)
+	}
+	args := make(map[string]interface{}, len(d.Params))
+	for i, param := range d.Params {
+		if i < len(u.Args) {
+			args[param] = evalExpr(u.Args[i], func(path string) interface{} { return c.resolvePath(path, vars) })
+		}
+	}
+	if c.expanding == nil {
+		c.expanding = make(map[string]bool)
+	}
+	c.expanding[u.Name] = true
+	defer delete(c.expanding, u.Name)
+	return c.renderNodes(d.Body, args, buf)
+}
+
+// sectionAttrs are the section attributes that control rendering; any
+// others are copied onto the section element.
+var sectionAttrs = map[string]bool{"tab": true, "default": true, "hidden": true, "element": true, "slot": true}
+
+// sectionTag returns the opening tag for a section and the element it uses,
+// section unless the element attribute says otherwise.
+func sectionTag(s Section) (string, string) {
+	el := "section"
+	if v, ok := s.Attr("element"); ok {
+		el = v
+	}
+	var b strings.Builder
+	b.WriteString("<" + el + ` class="section`)
+	for _, class := range s.Classes {
+		b.WriteString(" " + htmlEscape(class))
+	}
+	b.WriteString(`" id="` + htmlEscape(s.ID) + `"`)
+	for _, a := range s.Attrs {
+		if sectionAttrs[a.Name] {
+			continue
+		}
+		b.WriteString(" " + a.Name)
+		if a.Value != "" {
+			b.WriteString(`="` + htmlEscape(a.Value) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String(), el
+}
+
+// tabSections returns the sections marked with a tab attribute, in document
+// order, leaving out hidden ones.
+func tabSections(nodes []Node) []Section {
+	var tabs []Section
+	InspectAll(nodes, func(n Node) bool {
+		if s, ok := n.(Section); ok {
+			_, tab := s.Attr("tab")
+			_, hidden := s.Attr("hidden")
+			if tab && !hidden {
+				tabs = append(tabs, s)
+			}
+		}
+		return true
+	})
+	return tabs
+}
+
+// tabLabel is the text of a section's tab: the tab attribute's value, or
+// the capitalized section id for a bare tab flag.
+func tabLabel(s Section) string {
+	if v, _ := s.Attr("tab"); v != "" {
+		return v
+	}
+	if s.ID == "" {
+		return ""
+	}
+	return strings.ToUpper(s.ID[:1]) + s.ID[1:]
+}
+
+// tabBar renders the tab bar for tabs. The active tab is the one marked
+// default, or else the first.
+func tabBar(tabs []Section) string {
+	active := 0
+	for i, s := range tabs {
+		if _, ok := s.Attr("default"); ok {
+			active = i
+			break
+		}
+	}
+	var b strings.Builder
+	b.WriteString(`
+<div class="container">
+  <div class="tabs is-centered is-large is-boxed has-text-weight-semibold is-family-code is-lowercase">
+    <ul>`)
+	for i, s := range tabs {
+		class := ""
+		if i == active {
+			class = ` class="is-active"`
+		}
+		fmt.Fprintf(&b, `
+      <li%s data-tab="%s">
+        <a>
+          <span>%s</span>
+        </a>
+      </li>`, class, htmlEscape(s.ID), htmlEscape(tabLabel(s)))
+	}
+	b.WriteString(`
+    </ul>
+  </div>
+</div>`)
+	return b.String()
+}
+
 func (c *context) renderLoop(l Loop, vars map[string]interface{}, buf *strings.Builder) error {
 	src := c.resolvePath(l.Source, vars)
 	switch arr := src.(type) {
 	case []interface{}:
 		items := append([]interface{}{}, arr...)
-		
-		// Check if this is an apps loop and filter for software items only
-		// Based on SPEC.md: "Renderer should use apps.results filtered to items where kind == 'software'"
-		// Only apply filtering if we detect this is apps data (has wrapperType or kind fields)
-		isAppsData := false
-		if len(items) > 0 {
-			if itemMap, ok := items[0].(map[string]interface{}); ok {
-				if _, hasKind := itemMap["kind"]; hasKind {
-					isAppsData = true
-				} else if _, hasWrapper := itemMap["wrapperType"]; hasWrapper {
-					isAppsData = true
-				}
-			}
-		}
-		
-		if isAppsData {
-			filteredItems := make([]interface{}, 0, len(items))
-			for _, item := range items {
-				if itemMap, ok := item.(map[string]interface{}); ok {
-					// Check if this is a software app (not an artist entry)
-					if kind, hasKind := itemMap["kind"]; hasKind && kind == "software" {
-						filteredItems = append(filteredItems, item)
-					} else if wrapperType, hasWrapper := itemMap["wrapperType"]; hasWrapper && wrapperType == "software" {
-						// Also check wrapperType for additional safety
-						filteredItems = append(filteredItems, item)
-					}
-				}
-			}
-			items = filteredItems
+		if l.GroupBy != "" {
+			// where tests the items, before they are grouped
+			flat := l
+			flat.Vars = nil
+			items = c.filter(flat, items, vars, false)
+			return c.renderEntries(l, groupItems(items, l.GroupBy), vars, buf)
 		}
 		
+		items = c.filter(l, items, vars, false)
 		SortSlice(items, l.Sort)
-		
-		// Check loop type by examining the first item
-		isAppsLoop := false
-		isThingsLoop := false
-		isReposLoop := false
-		if len(items) > 0 {
-			if item, ok := items[0].(map[string]interface{}); ok {
-				// Check if this is an apps loop by looking for app-specific fields
-				if _, hasTrackName := item["trackName"]; hasTrackName {
-					if _, hasTrackViewUrl := item["trackViewUrl"]; hasTrackViewUrl {
-						if _, hasGenres := item["genres"]; hasGenres {
-							isAppsLoop = true
-						}
-					}
-				}
-				// Check if this is a things loop by looking for thing-specific fields
-				if _, hasTitle := item["title"]; hasTitle {
-					if _, hasUrl := item["url"]; hasUrl {
-						if _, hasCategory := item["category"]; hasCategory {
-							if _, hasDatePublished := item["date_published"]; hasDatePublished {
-								isThingsLoop = true
-							}
-						}
-					}
-				}
-				// Check if this is a repos loop by looking for repo-specific fields
-				if _, hasName := item["name"]; hasName {
-					if _, hasHtmlUrl := item["html_url"]; hasHtmlUrl {
-						if _, hasStargazersCount := item["stargazers_count"]; hasStargazersCount {
-							if _, hasUpdatedAt := item["updated_at"]; hasUpdatedAt {
-								isReposLoop = true
-							}
-						}
-					}
-				}
-			}
-		}
-		
-		if isAppsLoop {
-			buf.WriteString(`<section class="section">`)
-			buf.WriteString(`<div class="container">`)
-			buf.WriteString(`<div class="grid is-col-min-16">`)
-			for _, it := range items {
-				nv := map[string]interface{}{}
-				if len(l.Vars) > 0 {
-					nv[l.Vars[0]] = it
-				}
-				buf.WriteString(`<div class="cell">`)
-				if err := c.renderAppCard(l.Body, merge(vars, nv), buf); err != nil {
-					return err
-				}
-				buf.WriteString(`</div>`)
-			}
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</section>`)
-			buf.WriteString(`</div>`) // Close tab-content div for apps
-		} else if isThingsLoop {
-			// Group things by category and collect unique categories
-			categoryMap := make(map[string][]interface{})
-			categoryOrder := []string{}
-			
-			for _, it := range items {
-				if itemMap, ok := it.(map[string]interface{}); ok {
-					category := fmt.Sprintf("%v", itemMap["category"])
-					if _, exists := categoryMap[category]; !exists {
-						categoryOrder = append(categoryOrder, category)
-						categoryMap[category] = []interface{}{}
-					}
-					categoryMap[category] = append(categoryMap[category], it)
-				}
-			}
-			
-			// Generate category filter menu
-			buf.WriteString(`<div class="category-filter-wrapper">`)
-			buf.WriteString(`<div class="category-filter-scroll">`)
-			buf.WriteString(`<div class="level is-mobile category-filter-level">`)
-			buf.WriteString(`<div class="level-item">`)
-			buf.WriteString(`<div class="buttons has-addons category-filter-buttons">`)
-			buf.WriteString(`<button class="button is-info is-selected" onclick="filterThings('all')">All</button>`)
-			
-			for _, category := range categoryOrder {
-				capitalizedCategory := category
-				if len(category) > 0 {
-					capitalizedCategory = strings.ToUpper(category[:1]) + category[1:]
-				}
-				buf.WriteString(`<button class="button" onclick="filterThings('` + category + `')">`)
-				buf.WriteString(htmlEscape(capitalizedCategory))
-				buf.WriteString(`</button>`)
-			}
-			
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			
-			// Render all things in one section with category data attributes
-			buf.WriteString(`<section class="section">`)
-			buf.WriteString(`<div class="container">`)
-			buf.WriteString(`<div class="grid is-col-min-16" id="things-grid">`)
-			
-			for _, category := range categoryOrder {
-				for _, it := range categoryMap[category] {
-					nv := map[string]interface{}{}
-					if len(l.Vars) > 0 {
-						nv[l.Vars[0]] = it
-					}
-					buf.WriteString(`<div class="cell thing-item" data-category="` + category + `">`)
-					if err := c.renderThingCard(l.Body, merge(vars, nv), buf); err != nil {
-						return err
-					}
-					buf.WriteString(`</div>`)
-				}
-			}
-			
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</section>`)
-			buf.WriteString(`</div>`) // Close tab-content div for things
-		} else if isReposLoop {
-			buf.WriteString(`<section class="section">`)
-			buf.WriteString(`<div class="container">`)
-			buf.WriteString(`<div class="grid is-col-min-16">`)
-			for _, it := range items {
-				nv := map[string]interface{}{}
-				if len(l.Vars) > 0 {
-					nv[l.Vars[0]] = it
-				}
-				buf.WriteString(`<div class="cell">`)
-				if err := c.renderRepoCard(l.Body, merge(vars, nv), buf); err != nil {
-					return err
-				}
-				buf.WriteString(`</div>`)
-			}
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</div>`)
-			buf.WriteString(`</section>`)
-			buf.WriteString(`</div>`) // Close tab-content div for repos
-		} else {
-			buf.WriteString(`<section class="section">`)
-			for _, it := range items {
-				nv := map[string]interface{}{}
-				if len(l.Vars) > 0 {
-					nv[l.Vars[0]] = it
-				}
-				buf.WriteString(`<div class="box">`)
-				if err := c.renderNodes(l.Body, merge(vars, nv), buf); err != nil {
-					return err
-				}
-				buf.WriteString(`</div>`)
-			}
-			buf.WriteString(`</section>`)
-		}
+		items = l.window(items)
+		return c.present(l, items, vars, false, buf)
 	case map[string]interface{}:
+		if l.GroupBy != "" {
+			c.warnf(l.Pos(), "%s is an object, not an array; cannot group it", l.Source)
+			return nil
+		}
 		keys := make([]interface{}, 0, len(arr))
 		for k, v := range arr {
 			keys = append(keys, map[string]interface{}{"key": k, "value": v})
 		}
-		// for map, sort by provided sort keys applied on struct with key/value
-		SortSlice(keys, l.Sort)
-		buf.WriteString(`<section class="section">`)
-		for _, kv := range keys {
-			m := kv.(map[string]interface{})
-			nv := map[string]interface{}{}
-			if len(l.Vars) == 2 {
-				nv[l.Vars[0]] = m["key"]
-				nv[l.Vars[1]] = m["value"]
-			} else if len(l.Vars) == 1 {
-				nv[l.Vars[0]] = m["value"]
-			}
-			buf.WriteString(`<div class="box">`)
-			if err := c.renderNodes(l.Body, merge(vars, nv), buf); err != nil {
-				return err
-			}
-			buf.WriteString(`</div>`)
+		return c.renderEntries(l, c.filter(l, keys, vars, true), vars, buf)
+	case nil:
+		head := strings.SplitN(l.Source, ".", 2)[0]
+		if _, ok := vars[head]; !ok && c.bindings[head] == nil && c.lazy[head] == nil {
+			c.warnf(l.Pos(), "unknown source %s; skipping loop", head)
+		}
+	default:
+		c.warnf(l.Pos(), "%s is not an array or object; skipping loop", l.Source)
+	}
+	return nil
+}
+
+// renderEntries renders a loop over {key, value} entries: the keys and values
+// of an object, or the groups of a group by loop.
+func (c *context) renderEntries(l Loop, entries []interface{}, vars map[string]interface{}, buf *strings.Builder) error {
+	SortSlice(entries, l.entrySort())
+	entries = l.window(entries)
+	return c.present(l, entries, vars, true, buf)
+}
+
+// hasMarkup reports whether a loop body writes its own HTML with raw lines
+// or macros, outside of any nested loops.
+func hasMarkup(body []Node) bool {
+	found := false
+	InspectAll(body, func(n Node) bool {
+		switch n.(type) {
+		case Raw, Use:
+			found = true
+		case Loop:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// renderBodies renders the loop body for each item with no wrapping markup,
+// for loops whose body supplies its own.
+func (c *context) renderBodies(l Loop, items []interface{}, vars map[string]interface{}, entries bool, buf *strings.Builder) error {
+	for i, it := range items {
+		if err := c.renderNodes(l.Body, merge(vars, l.iterVars(vars, it, entries, i, len(items))), buf); err != nil {
+			return err
 		}
-		buf.WriteString(`</section>`)
 	}
 	return nil
 }
 
+// groupItems collects items into {key, value} entries keyed by the value at
+// path, in order of first appearance.
+func groupItems(items []interface{}, path string) []interface{} {
+	var groups []interface{}
+	byKey := make(map[string]map[string]interface{})
+	for _, it := range items {
+		k := Resolve(it, path)
+		g, ok := byKey[fmt.Sprint(k)]
+		if !ok {
+			g = map[string]interface{}{"key": k, "value": []interface{}{}}
+			byKey[fmt.Sprint(k)] = g
+			groups = append(groups, g)
+		}
+		g["value"] = append(g["value"].([]interface{}), it)
+	}
+	return groups
+}
+
+func hasFields(item interface{}, names ...string) bool {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, name := range names {
+		if _, ok := m[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// entrySort rewrites sort keys written in terms of the loop vars, e.g.
+// `count` in [for name, count in languages: count], to the {key, value}
+// fields of an entry.
+func (l Loop) entrySort() []SortKey {
+	keys := make([]SortKey, len(l.Sort))
+	for i, k := range l.Sort {
+		head, rest, dotted := strings.Cut(k.Path, ".")
+		switch {
+		case len(l.Vars) == 2 && head == l.Vars[0]:
+			head = "key"
+		case len(l.Vars) > 0 && head == l.Vars[len(l.Vars)-1]:
+			head = "value"
+		}
+		if dotted {
+			head += "." + rest
+		}
+		keys[i] = SortKey{Path: head, Asc: k.Asc}
+	}
+	return keys
+}
+
+// itemVars binds an item to the loop's vars. Map entries are {key, value}
+// objects: with two vars they bind to key and value, with one to value.
+func (l Loop) itemVars(it interface{}, entry bool) map[string]interface{} {
+	nv := map[string]interface{}{}
+	if !entry {
+		if len(l.Vars) > 0 {
+			nv[l.Vars[0]] = it
+		}
+		return nv
+	}
+	m := it.(map[string]interface{})
+	if len(l.Vars) == 2 {
+		nv[l.Vars[0]] = m["key"]
+		nv[l.Vars[1]] = m["value"]
+	} else if len(l.Vars) == 1 {
+		nv[l.Vars[0]] = m["value"]
+	}
+	return nv
+}
+
+// iterVars returns the vars for item i of n: the loop's vars plus a loop
+// var with the iteration's index, first, last, length and the enclosing
+// loop's loop var as parent. A loop var named loop takes precedence.
+func (l Loop) iterVars(vars map[string]interface{}, it interface{}, entry bool, i, n int) map[string]interface{} {
+	nv := map[string]interface{}{
+		"loop": map[string]interface{}{
+			"index":  i,
+			"index1": i + 1,
+			"first":  i == 0,
+			"last":   i == n-1,
+			"length": n,
+			"parent": vars["loop"],
+		},
+	}
+	for k, v := range l.itemVars(it, entry) {
+		nv[k] = v
+	}
+	return nv
+}
+
+// filter keeps the items that satisfy the loop's where clause. Paths in the
+// clause resolve against the item first, then against the loop vars.
+func (c *context) filter(l Loop, items []interface{}, vars map[string]interface{}, entries bool) []interface{} {
+	if l.Where == nil {
+		return items
+	}
+	kept := make([]interface{}, 0, len(items))
+	for _, it := range items {
+		scope := merge(vars, l.itemVars(it, entries))
+		lookup := func(path string) interface{} {
+			if v := Resolve(it, path); v != nil {
+				return v
+			}
+			return c.resolvePath(path, scope)
+		}
+		if truthy(evalExpr(l.Where, lookup)) {
+			kept = append(kept, it)
+		}
+	}
+	return kept
+}
+
 func merge(a, b map[string]interface{}) map[string]interface{} {
 	out := make(map[string]interface{}, len(a)+len(b))
 	for k, v := range a {
@@ -464,26 +1046,34 @@ func merge(a, b map[string]interface{}) map[string]interface{} {
 }
 
 func (c *context) resolvePath(path string, vars map[string]interface{}) interface{} {
+	v, _ := c.lookupPath(path, vars)
+	return v
+}
+
+// lookupPath is resolvePath that also reports whether the path exists, so
+// that a field that is there but null isn't warned about as missing.
+func (c *context) lookupPath(path string, vars map[string]interface{}) (interface{}, bool) {
 	parts := strings.Split(path, ".")
 	if len(parts) == 0 {
-		return nil
+		return nil, false
 	}
 	head := parts[0]
 	var obj interface{}
+	found := true
 	if v, ok := vars[head]; ok {
 		obj = v
 	} else if v, ok := c.bindings[head]; ok {
 		obj = v
 	} else if lb, ok := c.lazy[head]; ok {
 		obj = c.resolveLazy(lb, head, vars)
+		found = obj != nil
+	} else {
+		found = false
 	}
-	if obj == nil {
-		return nil
-	}
-	if len(parts) == 1 {
-		return obj
+	if len(parts) == 1 || !found {
+		return obj, found
 	}
-	return Resolve(obj, strings.Join(parts[1:], "."))
+	return lookup(obj, strings.Join(parts[1:], "."))
 }
 
 var tmplRe = regexp.MustCompile(`\{([^}]+)\}`)
@@ -513,7 +1103,7 @@ func (c *context) resolveLazy(lb *lazyBinding, name string, vars map[string]inte
 		key = url
 	}
 	if val, ok := lb.Data[key]; ok {
-		return val
+		return c.lazyValue(lb, val)
 	}
 	if lb.Fetched[key] {
 		return nil
@@ -528,7 +1118,7 @@ func (c *context) resolveLazy(lb *lazyBinding, name string, vars map[string]inte
 		return nil
 	}
 	lb.Data[key] = v
-	return v
+	return c.lazyValue(lb, v)
 }
 
 func htmlEscape(s string) string {
@@ -586,264 +1176,11 @@ func slugify(s string) string {
 	return s
 }
 
-func (c *context) renderAppCard(nodes []Node, vars map[string]interface{}, buf *strings.Builder) error {
-	// Extract app data from variables
-	app := vars["app"]
-	if app == nil {
-		return c.renderNodes(nodes, vars, buf)
-	}
-	
-	appMap, ok := app.(map[string]interface{})
-	if !ok {
-		return c.renderNodes(nodes, vars, buf)
-	}
-	
-	trackName := fmt.Sprintf("%v", appMap["trackName"])
-	trackViewUrl := fmt.Sprintf("%v", appMap["trackViewUrl"])
-	description := fmt.Sprintf("%v", appMap["description"])
-	artworkUrl100 := fmt.Sprintf("%v", appMap["artworkUrl100"])
-	genres := appMap["genres"]
-	
-	// Truncate description to around 200 characters with ellipsis, breaking on word boundaries
-	truncatedDesc := description
-	if len(description) > 200 {
-		// Find the last space within the first 200 characters
-		cutoff := 200
-		lastSpace := -1
-		for i := cutoff - 1; i >= 0; i-- {
-			if description[i] == ' ' {
-				lastSpace = i
-				break
-			}
-		}
-		
-		// If we found a space within reasonable distance, use it; otherwise fallback to character cutoff
-		if lastSpace > cutoff - 50 { // Don't go back more than 50 characters to find a space
-			truncatedDesc = description[:lastSpace] + "..."
-		} else {
-			truncatedDesc = description[:200] + "..."
-		}
-	}
-	
-	buf.WriteString(`<div class="card">
-  <div class="card-content">
-    <div class="content">`)
-	
-	// Add app icon and title in a flex layout
-	if artworkUrl100 != "" && artworkUrl100 != "<no value>" {
-		buf.WriteString(`
-      <div class="level is-mobile" style="margin-bottom: 1rem;">
-        <div class="level-left">
-          <div class="level-item">
-            <figure class="image is-48x48" style="margin-right: 0.75rem;">
-              <img src="`)
-		buf.WriteString(htmlEscape(artworkUrl100))
-		buf.WriteString(`" alt="`)
-		buf.WriteString(htmlEscape(trackName))
-		buf.WriteString(` icon" style="border-radius: 12px;">
-            </figure>
-          </div>
-          <div class="level-item">
-            <h3 class="title is-5" style="margin-bottom: 0;">
-              <a href="`)
-		buf.WriteString(htmlEscape(trackViewUrl))
-		buf.WriteString(`" target="_blank" rel="noopener noreferrer">`)
-		buf.WriteString(htmlEscape(trackName))
-		buf.WriteString(`</a>
-            </h3>
-          </div>
-        </div>
-      </div>`)
-	} else {
-		buf.WriteString(`
-      <h3 class="title is-5">
-        <a href="`)
-		buf.WriteString(htmlEscape(trackViewUrl))
-		buf.WriteString(`" target="_blank" rel="noopener noreferrer">`)
-		buf.WriteString(htmlEscape(trackName))
-		buf.WriteString(`</a>
-      </h3>`)
-	}
-	
-	buf.WriteString(`
-      <p>`)
-	buf.WriteString(htmlEscape(truncatedDesc))
-	buf.WriteString(`</p>
-    </div>
-  </div>`)
-	
-	// Add categories section if genres exist
-	if genres != nil {
-		if genreSlice, ok := genres.([]interface{}); ok && len(genreSlice) > 0 {
-			buf.WriteString(`
-  <div class="card-footer">
-    <div class="card-footer-item">
-      <div class="tags">`)
-			for _, genre := range genreSlice {
-				genreStr := fmt.Sprintf("%v", genre)
-				buf.WriteString(`
-        <span class="tag is-info is-light">`)
-				buf.WriteString(htmlEscape(genreStr))
-				buf.WriteString(`</span>`)
-			}
-			buf.WriteString(`
-      </div>
-    </div>
-  </div>`)
-		}
-	}
-	
-	buf.WriteString(`
-</div>`)
-	
-	return nil
-}
-
-func (c *context) renderThingCard(nodes []Node, vars map[string]interface{}, buf *strings.Builder) error {
-	// Extract thing data from variables
-	thing := vars["thing"]
-	if thing == nil {
-		return c.renderNodes(nodes, vars, buf)
-	}
-	
-	thingMap, ok := thing.(map[string]interface{})
-	if !ok {
-		return c.renderNodes(nodes, vars, buf)
-	}
-	
-	title := fmt.Sprintf("%v", thingMap["title"])
-	url := fmt.Sprintf("%v", thingMap["url"])
-	description := fmt.Sprintf("%v", thingMap["description"])
-	category := fmt.Sprintf("%v", thingMap["category"])
-	slug := slugify(title)
-	
-	// Use full description without truncation for things
-	truncatedDesc := description
-	
-	buf.WriteString(`<div class="card" id="`)
-	buf.WriteString(htmlEscape(slug))
-	buf.WriteString(`">
-  <button class="clipboard-btn" onclick="copyCardLink(event, '`)
-	buf.WriteString(htmlEscape(category))
-	buf.WriteString(`', '`)
-	buf.WriteString(htmlEscape(slug))
-	buf.WriteString(`')" title="Copy link to clipboard">
-    <svg xmlns="http://www.w3.org/2000/svg" width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round">
-      <rect x="9" y="9" width="13" height="13" rx="2" ry="2"></rect>
-      <path d="M5 15H4a2 2 0 0 1-2-2V4a2 2 0 0 1 2-2h9a2 2 0 0 1 2 2v1"></path>
-    </svg>
-  </button>
-  <div class="card-content">
-    <div class="content">
-      <h3 class="title is-5">
-        <a href="`)
-	buf.WriteString(htmlEscape(url))
-	buf.WriteString(`" target="_blank" rel="noopener noreferrer">`)
-	buf.WriteString(htmlEscape(title))
-	buf.WriteString(`</a>
-      </h3>
-      <p>`)
-	buf.WriteString(htmlEscape(truncatedDesc))
-	buf.WriteString(`</p>
-    </div>
-  </div>`)
-	
-	// Add category section
-	if category != "" {
-		buf.WriteString(`
-  <div class="card-footer">
-    <div class="card-footer-item">
-      <div class="tags">
-        <span class="tag is-info is-light">`)
-		buf.WriteString(htmlEscape(category))
-		buf.WriteString(`</span>
-      </div>
-    </div>
-  </div>`)
-	}
-	
-	buf.WriteString(`
-</div>`)
-	
-	return nil
-}
-
-func (c *context) renderRepoCard(nodes []Node, vars map[string]interface{}, buf *strings.Builder) error {
-	// Extract repo data from variables
-	repo := vars["repo"]
-	if repo == nil {
-		return c.renderNodes(nodes, vars, buf)
-	}
-	
-	repoMap, ok := repo.(map[string]interface{})
-	if !ok {
-		return c.renderNodes(nodes, vars, buf)
-	}
-	
-	name := fmt.Sprintf("%v", repoMap["name"])
-	htmlUrl := fmt.Sprintf("%v", repoMap["html_url"])
-	description := fmt.Sprintf("%v", repoMap["description"])
-	stargazersCount := fmt.Sprintf("%v", repoMap["stargazers_count"])
-	updatedAt := fmt.Sprintf("%v", repoMap["updated_at"])
-	language := fmt.Sprintf("%v", repoMap["language"])
-	
-	// Parse and format the date
-	var formattedDate string
-	if t, err := time.Parse(time.RFC3339, updatedAt); err == nil {
-		formattedDate = t.Format("January 2, 2006")
-	} else {
-		formattedDate = updatedAt
-	}
-	
-	buf.WriteString(`<div class="card">
-  <div class="card-content">
-    <div class="content">
-      <h3 class="title is-5">
-        <a href="`)
-	buf.WriteString(htmlEscape(htmlUrl))
-	buf.WriteString(`" target="_blank" rel="noopener noreferrer">`)
-	buf.WriteString(htmlEscape(name))
-	buf.WriteString(`</a>
-      </h3>
-      <p>`)
-	buf.WriteString(htmlEscape(description))
-	buf.WriteString(`</p>
-      <div class="level">
-        <div class="level-left">
-          <div class="level-item">
-            <svg xmlns="http://www.w3.org/2000/svg" width="16" height="16" style="margin-right: 4px;">
-              <path d="M8 .2l4.9 15.2L0 6h16L3.1 15.4z"/>
-            </svg>
-            <span>`)
-	buf.WriteString(htmlEscape(stargazersCount))
-	buf.WriteString(`</span>
-          </div>`)
-	
-	// Add language if it exists and is not "<no value>"
-	if language != "" && language != "<no value>" {
-		buf.WriteString(`
-          <div class="level-item">
-            <span class="tag is-info is-light">`)
-		buf.WriteString(htmlEscape(language))
-		buf.WriteString(`</span>
-          </div>`)
-	}
-	
-	buf.WriteString(`
-        </div>
-      </div>
-    </div>
-  </div>
-  <footer class="card-footer">
-    <p class="card-footer-item has-text-grey-light">
-      Last updated `)
-	buf.WriteString(htmlEscape(formattedDate))
-	buf.WriteString(`
-    </p>
-  </footer>
-</div>`)
-	
-	return nil
+// thingFuncs write the comments in templates/thing.html, which html/template
+// would otherwise strip.
+var thingFuncs = template.FuncMap{
+	"comment":   func(s string) template.HTML { return template.HTML("<!-- " + strings.ReplaceAll(s, "--", "") + " -->") },
+	"jsComment": func(s string) template.JS { return template.JS("// " + strings.NewReplacer("\n", " ", "\r", " ").Replace(s)) },
 }
 
 func (c *context) generateThingPages(outDir string) error {
@@ -858,13 +1195,14 @@ func (c *context) generateThingPages(outDir string) error {
 		return nil
 	}
 	
-	// Load template
+	// Load template; html/template escapes each value for where it lands,
+	// including the redirect script. It also drops comments, so the template
+	// writes them through thingFuncs to keep them in the pages.
 	templatePath := "templates/thing.html"
-	templateBytes, err := os.ReadFile(templatePath)
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(thingFuncs).ParseFiles(templatePath)
 	if err != nil {
 		return err
 	}
-	template := string(templateBytes)
 	
 	// Generate page for each thing
 	for _, t := range things {
@@ -879,12 +1217,23 @@ func (c *context) generateThingPages(outDir string) error {
 		slug := slugify(title)
 		url := fmt.Sprintf("https://hithisisme.com/things/%s/%s.html", category, slug)
 		
-		// Replace placeholders
-		page := strings.ReplaceAll(template, "{{TITLE}}", htmlEscape(title))
-		page = strings.ReplaceAll(page, "{{DESCRIPTION}}", htmlEscape(description))
-		page = strings.ReplaceAll(page, "{{CATEGORY}}", htmlEscape(category))
-		page = strings.ReplaceAll(page, "{{SLUG}}", htmlEscape(slug))
-		page = strings.ReplaceAll(page, "{{URL}}", htmlEscape(url))
+		// The category names a directory, so it must not leave things/
+		if category == "" || category == "." || category == ".." || strings.ContainsAny(category, `/\`) {
+			c.warnf(c.declared["things"].Pos(), "skipping the page for %q: category %q is not a directory name", title, category)
+			continue
+		}
+		
+		var page bytes.Buffer
+		err := tmpl.Execute(&page, map[string]string{
+			"Title":       title,
+			"Description": description,
+			"Category":    category,
+			"Slug":        slug,
+			"URL":         url,
+		})
+		if err != nil {
+			return err
+		}
 		
 		// Create directory
 		thingDir := filepath.Join(outDir, "things", category)
@@ -894,7 +1243,7 @@ func (c *context) generateThingPages(outDir string) error {
 		
 		// Write file
 		filePath := filepath.Join(thingDir, slug+".html")
-		if err := os.WriteFile(filePath, []byte(page), 0o644); err != nil {
+		if err := os.WriteFile(filePath, page.Bytes(), 0o644); err != nil {
 			return err
 		}
 	}
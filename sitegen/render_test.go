@@ -0,0 +1,305 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("HI_TEST_USER", "env-user")
+	t.Setenv("HI_TEST_ID", "42")
+	vars := map[string]string{"HI_TEST_USER": "flag-user"}
+	got, err := expandVars("https://example.com/${HI_TEST_USER}/{repo.name}?id=${HI_TEST_ID}", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/flag-user/{repo.name}?id=42"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	_, err = expandVars("users/${HI_TEST_UNSET}/repos", vars)
+	if want := "${HI_TEST_UNSET} is not set; set HI_TEST_UNSET in the environment or pass -var HI_TEST_UNSET=<value>"; err == nil || err.Error() != want {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := expandVars("users/${HI_TEST_USER", vars); err == nil {
+		t.Fatal("expected error for unterminated ${")
+	}
+}
+
+func TestBindingTransforms(t *testing.T) {
+	src := `apps = apps.json << https://example.com/lookup | .results | where kind == "software" and trackName != "a|b"` + "\n"
+	bindings, _, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bindings[0]
+	if b.URL != "https://example.com/lookup" || len(b.Transforms) != 2 || b.Transforms[0].Path != "results" {
+		t.Fatalf("unexpected binding %+v", b)
+	}
+	raw := map[string]interface{}{"results": []interface{}{
+		map[string]interface{}{"wrapperType": "artist"},
+		map[string]interface{}{"kind": "software", "trackName": "Vortex"},
+		map[string]interface{}{"kind": "software", "trackName": "a|b"},
+	}}
+	c := &context{bindings: map[string]interface{}{}}
+	if err := c.bind(b, raw); err != nil {
+		t.Fatal(err)
+	}
+	apps, ok := c.bindings["apps"].([]interface{})
+	if !ok || len(apps) != 1 || Resolve(apps[0], "trackName") != "Vortex" {
+		t.Fatalf("unexpected value %v", c.bindings["apps"])
+	}
+	if len(raw["results"].([]interface{})) != 3 {
+		t.Fatal("transform modified the raw value")
+	}
+
+	b.Transforms = b.Transforms[1:]
+	if err := c.bind(b, raw); err == nil || err.Error() != `<input>:1:1: binding apps: where kind == "software" and trackName != "a|b": value is not an array` {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, _, err := Parse(strings.NewReader("things = things.json | sort title\n")); err == nil || err.Error() != `<input>:1:24: invalid transform "sort title": expected .<path> or where <expr>` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRenderSectionAttrs(t *testing.T) {
+	src := `{hero: Hi}
+{things tab="My things": Things}
+{secret hidden: Secret}
+[for x in xs]
+  x
+{notes tab .is-wide element=aside data-kind="a&b": Notes}
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: map[string]interface{}{"xs": []interface{}{"leaked"}}, tabs: tabSections(nodes)}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<li class="is-active" data-tab="things">`,
+		`<span>My things</span>`,
+		`<li data-tab="notes">`,
+		`<span>Notes</span>`,
+		"<div id=\"notes-content\" class=\"tab-content\">\n<aside class=\"section is-wide\" id=\"notes\" data-kind=\"a&amp;b\">",
+		"</aside>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Secret") || strings.Contains(out, "leaked") {
+		t.Errorf("hidden section was rendered:\n%s", out)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	src := `[define card(item, label)]
+  <div class="card" data-label="{label}">
+  <a href="{item.url}">{item.title}</a>
+  item.notes | default "none"
+  [for tag in item.tags]
+    <span class="tag">{tag}</span>
+  </div>
+
+[for thing in things]
+  [use card thing "pick"]
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: map[string]interface{}{"things": []interface{}{
+		map[string]interface{}{"title": "Tools & <toys>", "url": "https://example.com/?a=1&b=2", "tags": []interface{}{"x", "y"}},
+	}}, macros: macroDefs(nodes), stderr: &strings.Builder{}}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `<div class="card" data-label="pick">
+<a href="https://example.com/?a=1&amp;b=2">Tools &amp; &lt;toys&gt;</a>
+<p>none</p><span class="tag">x</span>
+<span class="tag">y</span>
+</div>
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	src := `[define card(item)]
+  item.title
+[define card(x)]
+  x
+[use card]
+[use missing a]
+[use card a ==]
+`
+	_, _, err := Parse(strings.NewReader(src))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	want := []string{
+		"<input>:3:1: macro card redefined; previously defined at <input>:1:1",
+		"<input>:5:1: macro card takes 1 argument, got 0",
+		"<input>:6:1: undefined macro missing",
+		"<input>:7:13: invalid argument: unexpected ==",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), list)
+	}
+	for i, w := range want {
+		if got := list[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+	// found after parsing, but still pointing into the use line
+	if got, want := list[2].Hint(), "[use missing a]\n^"; got != want {
+		t.Errorf("hint = %q, want %q", got, want)
+	}
+
+	_, nodes, err := Parse(strings.NewReader("[define loop(x)]\n  [use loop x]\n[use loop 1]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{macros: macroDefs(nodes)}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err == nil || err.Error() != "<input>:2:3: macro loop uses itself" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRenderTabs(t *testing.T) {
+	src := `{intro: Hi}
+{apps tab: Apps}
+[for x in xs as box]
+  x
+<p>after the loop</p>
+{notes tab="My notes" default: Notes}
+{footer: Bye}
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: map[string]interface{}{"xs": []interface{}{"a"}}, tabs: tabSections(nodes)}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	bar := strings.Index(out, `<div class="tabs`)
+	if bar < 0 || bar < strings.Index(out, `id="intro"`) || bar > strings.Index(out, `id="apps-content"`) {
+		t.Fatalf("tab bar is not between intro and the first tab:\n%s", out)
+	}
+	for _, want := range []string{
+		`<li data-tab="apps">`,
+		`<li class="is-active" data-tab="notes">`,
+		`<span>My notes</span>`,
+		"<p>after the loop</p>\n</div><div id=\"notes-content\" class=\"tab-content\">",
+		"</section></div><section class=\"section\" id=\"footer\">",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if n, m := strings.Count(out, "<div"), strings.Count(out, "</div>"); n != m {
+		t.Errorf("%d <div> but %d </div>:\n%s", n, m, out)
+	}
+}
+
+func TestRenderSlots(t *testing.T) {
+	src := `{hero .is-1 slot="hero": Hi *there*}
+{intro: Intro}
+[for x in xs slot sidebar]
+  x
+{aside slot="sidebar":
+  More **links**
+}
+<p>in the sidebar</p>
+{main slot="content": Main}
+[for x in xs slot nowhere]
+  x
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr strings.Builder
+	c := &context{bindings: map[string]interface{}{"xs": []interface{}{"a"}}, stderr: &stderr}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	layout := "<!--IF SLOT:hero--><header><!--SLOT:hero--></header><!--END SLOT:hero-->\n<main><!--CONTENT--></main>\n<nav><!--SLOT:sidebar--></nav>\n<footer><!--SLOT:footer--></footer>\n" +
+		"<!--IF SLOT:banner-->\n<div class=\"banner\"><!--SLOT:banner--></div>\n<!--END SLOT:banner-->\n</body>\n"
+	out := c.fillLayout("layout.html", layout, buf.String(), nodes)
+	c.warnUnfilledSlots()
+	for _, want := range []string{
+		`<header><h1 class="title is-1">Hi <em>there</em></h1></header>`,
+		`<section class="section" id="intro">`,
+		`<section class="section" id="main">`,
+		"<nav><section class=\"section\"><div class=\"box\"><p>a</p></div></section>",
+		"<div class=\"content\"><p>More <strong>links</strong></p>\n</div><p>in the sidebar</p>\n</nav>",
+		"<footer></footer>\n</body>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "SLOT") || strings.Contains(out, "banner") || strings.Contains(out, `id="aside"`) || strings.Contains(out, `id="hero"`) {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	want := "layout.html:4:9: warning: nothing fills slot footer\n" +
+		"<input>:10:1: warning: layout layout.html has no <!--SLOT:nowhere-->; its content is dropped\n" +
+		"layout.html:6:21: warning: nothing fills slot banner on any page\n"
+	if stderr.String() != want {
+		t.Errorf("got warnings\n%s\nwant\n%s", stderr.String(), want)
+	}
+}
+
+func TestRenderNestedTabs(t *testing.T) {
+	src := `{a tab: A}
+[if cfg.on]
+  {b tab: B}
+`
+	_, nodes, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &context{bindings: map[string]interface{}{"cfg": map[string]interface{}{"on": true}}, tabs: tabSections(nodes)}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, `class="tabs`); n != 1 {
+		t.Fatalf("got %d tab bars:\n%s", n, out)
+	}
+	if !strings.Contains(out, `<li data-tab="b">`) || !strings.Contains(out, `id="b-content"`) {
+		t.Errorf("nested tab is missing:\n%s", out)
+	}
+}
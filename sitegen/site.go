@@ -0,0 +1,245 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FrontMatter holds a page's settings, given as key: value lines between
+// --- lines at the top of its .hi file:
+//
+//	---
+//	title: About me
+//	layout: ../templates/plain.html
+//	---
+//
+// Layout is relative to the .hi file.
+type FrontMatter struct {
+	Title  string
+	Layout string
+}
+
+// frontMatterLine is a key: value line of a front matter block.
+type frontMatterLine struct {
+	Pos
+	Key, Value string // Value as written, quotes and all
+	Source     string
+}
+
+// parseFrontMatter reads the key: value lines between the --- lines that
+// open lines, if the first one is ---. Blank lines and # comments in
+// between are skipped. It returns the lines and how many lines the block
+// takes, closing --- included; without a closing --- it runs to the end of
+// lines. .hi pages and Markdown files share it; each
+// decides what the values mean.
+func parseFrontMatter(filename string, lines []string) ([]frontMatterLine, int, ErrorList) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil, 0, nil
+	}
+	var fields []frontMatterLine
+	var errs ErrorList
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "---" {
+			return fields, i + 1, errs
+		}
+		if isBlank(line) {
+			continue
+		}
+		pos := Pos{File: filename, Line: i + 1, Col: 1}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			errs = append(errs, &ParseError{Pos: pos, Msg: fmt.Sprintf("invalid front matter %q: expected key: value", line), Source: lines[i]})
+			continue
+		}
+		fields = append(fields, frontMatterLine{Pos: pos, Key: strings.TrimSpace(key), Value: strings.TrimSpace(val), Source: lines[i]})
+	}
+	errs = append(errs, &ParseError{Pos: Pos{File: filename, Line: 1, Col: 1}, Msg: "unterminated front matter: missing closing ---", Source: lines[0]})
+	return fields, len(lines), errs
+}
+
+// pageFrontMatter reads the front matter of a .hi page, which may only set
+// title and layout.
+func pageFrontMatter(filename string, lines []string) (FrontMatter, int, ErrorList) {
+	var fm FrontMatter
+	fields, n, errs := parseFrontMatter(filename, lines)
+	for _, f := range fields {
+		// quoted values are unquoted; anything else is taken as written
+		val := f.Value
+		if s, ok := frontMatterValue(val).(string); ok {
+			val = s
+		}
+		switch f.Key {
+		case "title":
+			fm.Title = val
+		case "layout":
+			fm.Layout = val
+		default:
+			errs = append(errs, &ParseError{Pos: f.Pos, Msg: "unknown front matter key " + f.Key, Source: f.Source})
+		}
+	}
+	return fm, n, errs
+}
+
+// splitFrontMatter reads the front matter at the start of src, if there is
+// any. It returns the settings, the number of lines they take and src with
+// those lines blanked, so that positions in the rest of the file are
+// unchanged.
+func splitFrontMatter(filename string, src []byte) (FrontMatter, int, []byte, error) {
+	lines := strings.Split(string(src), "\n")
+	fm, n, errs := pageFrontMatter(filename, lines)
+	if err := errs.Err(); err != nil {
+		return fm, 0, nil, err
+	}
+	if n == 0 {
+		return fm, 0, src, nil
+	}
+	blank := strings.Repeat("\n", n)
+	rest := strings.Join(lines[n:], "\n")
+	return fm, n, []byte(blank + rest), nil
+}
+
+// page is a .hi file to render.
+type page struct {
+	path     string
+	front    FrontMatter
+	bindings []Binding
+	nodes    []Node
+}
+
+// defaultTitle fills <!--TITLE--> for a page whose front matter has no title.
+const defaultTitle = "hi this is me"
+
+// title returns the page's title from its front matter, or defaultTitle.
+func (p *page) title() string {
+	if p.front.Title != "" {
+		return p.front.Title
+	}
+	return defaultTitle
+}
+
+// readPage reads and parses the .hi file at path.
+func readPage(path string) (*page, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fm, _, src, err := splitFrontMatter(path, src)
+	if err != nil {
+		return nil, err
+	}
+	bindings, nodes, err := ParseFile(path, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	return &page{path: path, front: fm, bindings: bindings, nodes: nodes}, nil
+}
+
+// RenderSite renders every .hi file under opts.Pages to the matching .html
+// file under opts.Out: pages/about.hi to about.html, pages/blog/index.hi to
+// blog/index.html. Files whose names start with _ are left out, so they can
+// hold includes.
+//
+// The pages share one set of bindings, each fetched once per run, and each
+// page sees a generated nav binding listing every page in URL order, with
+// its title, url and whether it is the current one.
+func RenderSite(opts RenderOptions) error {
+	var pages []*page
+	err := filepath.WalkDir(opts.Pages, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".hi" || strings.HasPrefix(d.Name(), "_") {
+			return nil
+		}
+		p, err := readPage(path)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no .hi files in %s", opts.Pages)
+	}
+	ctx, err := newContext(opts)
+	if err != nil {
+		return err
+	}
+	for _, p := range pages {
+		for _, b := range p.bindings {
+			if b.Name == "nav" {
+				return fmt.Errorf("%s: binding nav is reserved for the page list", b.Pos())
+			}
+		}
+		if err := ctx.loadBindings(p.bindings, opts); err != nil {
+			return err
+		}
+	}
+
+	urls := make(map[*page]string, len(pages))
+	for _, p := range pages {
+		rel, err := filepath.Rel(opts.Pages, p.path)
+		if err != nil {
+			return err
+		}
+		urls[p] = pageURL(rel)
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return urls[pages[i]] < urls[pages[j]] })
+	for _, p := range pages {
+		nav := make([]interface{}, len(pages))
+		for i, q := range pages {
+			title := q.front.Title
+			if title == "" && urls[q] == "/" {
+				title = defaultTitle
+			} else if title == "" {
+				// index pages are named after their directory
+				title = path.Base(strings.TrimSuffix(urls[q], ".html"))
+			}
+			nav[i] = map[string]interface{}{"title": title, "url": urls[q], "current": q == p}
+		}
+		out := filepath.Join(opts.Out, filepath.FromSlash(strings.TrimPrefix(urls[p], "/")))
+		if strings.HasSuffix(urls[p], "/") {
+			out = filepath.Join(out, "index.html")
+		}
+		if err := ctx.renderPage(p, out, opts.Layout, nav); err != nil {
+			return err
+		}
+	}
+	return ctx.finish(opts.Out, opts.DataDir)
+}
+
+// pageURL returns the site URL for the page at rel, a path relative to the
+// pages directory. Index pages are addressed by their directory.
+func pageURL(rel string) string {
+	url := "/" + strings.TrimSuffix(filepath.ToSlash(rel), ".hi")
+	if dir, base := path.Split(url); base == "index" {
+		return dir
+	}
+	return url + ".html"
+}
@@ -0,0 +1,193 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	src := "---\n# comment\ntitle: \"About: me\"\nlayout: plain.html\n---\n{about: Hi}\n"
+	fm, n, rest, err := splitFrontMatter("about.hi", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm.Title != "About: me" || fm.Layout != "plain.html" || n != 5 {
+		t.Fatalf("unexpected front matter %+v, %d lines", fm, n)
+	}
+	if want := "\n\n\n\n\n{about: Hi}\n"; string(rest) != want {
+		t.Fatalf("got %q, want %q", rest, want)
+	}
+	if _, n, rest, _ := splitFrontMatter("index.hi", []byte("{about: Hi}\n")); n != 0 || string(rest) != "{about: Hi}\n" {
+		t.Fatalf("unexpected split of a file without front matter: %d %q", n, rest)
+	}
+
+	_, _, _, err = splitFrontMatter("about.hi", []byte("---\ntitle Hi\nauthor: me\n"))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %v", err)
+	}
+	want := []string{
+		"about.hi:1:1: unterminated front matter: missing closing ---",
+		`about.hi:2:1: invalid front matter "title Hi": expected key: value`,
+		"about.hi:3:1: unknown front matter key author",
+	}
+	if len(list) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), list)
+	}
+	for i, w := range want {
+		if got := list[i].Error(); got != w {
+			t.Errorf("error %d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	_, nodes, err := Parse(strings.NewReader("---\ntitle: About\n---\n{about: Hi}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Pos().Line != 4 {
+		t.Fatalf("front matter is in the body: %#v", nodes)
+	}
+	if _, _, err := Parse(strings.NewReader("---\nauthor: me\n---\n")); err == nil || err.Error() != "<input>:2:1: unknown front matter key author" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	f, err := os.Open("../index.hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, nodes, err = ParseFile("index.hi", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		if fl, ok := n.(Field); ok && (fl.Path == "---" || strings.HasPrefix(fl.Path, "title")) {
+			t.Errorf("front matter line parsed as a field: %+v", fl)
+		}
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	for rel, want := range map[string]string{
+		"index.hi":        "/",
+		"about.hi":        "/about.html",
+		"blog/index.hi":   "/blog/",
+		"blog/first.hi":   "/blog/first.html",
+		"reindex.hi":      "/reindex.html",
+		"blog/reindex.hi": "/blog/reindex.html",
+	} {
+		if got := pageURL(filepath.FromSlash(rel)); got != want {
+			t.Errorf("pageURL(%s) = %s, want %s", rel, got, want)
+		}
+	}
+}
+
+func TestRenderSite(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// the CSS is regenerated from the last build when there is no template
+		"public/style.css": "{{THEME_COLORS}}\n",
+		"data/links.json":  `[{"name": "one"}, {"name": "two"}]`,
+		"layout.html":      "<title><!--TITLE--></title>\n<nav><!--SLOT:nav--></nav>\n<!--CONTENT-->\n",
+		"plain.html":       "<title>plain: <!--TITLE--></title>\n<nav><!--SLOT:nav--></nav>\n<!--CONTENT-->\n",
+		"pages/_nav.hi": `[for page in nav slot nav]
+  [if page.current]
+    <b>{page.title}</b>
+  [else]
+    <a href="{page.url}">{page.title}</a>
+`,
+		"pages/index.hi": `---
+title: Home
+---
+links = links.json
+[include _nav.hi]
+{intro: Links}
+[for link in links]
+  <p>{link.name}</p>
+`,
+		"pages/about.hi": `---
+title: About & me
+layout: ../plain.html
+---
+links = links.json
+[include _nav.hi]
+[for link in links limit 1]
+  <p>{link.name}</p>
+`,
+		"pages/blog/index.hi": `[include ../_nav.hi]
+{posts: Posts}
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := RenderOptions{
+		Pages:   filepath.Join(dir, "pages"),
+		Out:     filepath.Join(dir, "public"),
+		DataDir: filepath.Join(dir, "data"),
+		Layout:  filepath.Join(dir, "layout.html"),
+	}
+	if err := RenderSite(opts); err != nil {
+		t.Fatal(err)
+	}
+	nav := func(current string) string {
+		links := []string{`<a href="/">Home</a>`, `<a href="/about.html">About &amp; me</a>`, `<a href="/blog/">blog</a>`}
+		for i, l := range links {
+			if strings.Contains(l, ">"+current+"<") {
+				links[i] = "<b>" + current + "</b>"
+			}
+		}
+		return "<nav>" + strings.Join(links, "\n") + "\n</nav>"
+	}
+	for name, want := range map[string][]string{
+		"index.html":      {"<title>Home</title>", nav("Home"), "<p>one</p>\n<p>two</p>", `id="intro"`},
+		"about.html":      {"<title>plain: About &amp; me</title>", nav("About &amp; me"), "</nav>\n<p>one</p>\n\n"},
+		"blog/index.html": {"<title>hi this is me</title>", nav("blog"), `id="posts"`},
+	} {
+		out, err := os.ReadFile(filepath.Join(opts.Out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(string(out), w) {
+				t.Errorf("%s is missing %q:\n%s", name, w, out)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(opts.Out, "_nav.html")); err == nil {
+		t.Error("rendered _nav.hi as a page")
+	}
+
+	os.WriteFile(filepath.Join(dir, "pages/blog/index.hi"), []byte("links = other.json\n"), 0o644)
+	want := filepath.Join(dir, "pages/blog/index.hi") + ":1:1: binding links redeclared; previously declared at " + filepath.Join(dir, "pages/about.hi") + ":5:1"
+	if err := RenderSite(opts); err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
@@ -16,39 +16,196 @@
 
 package sitegen
 
+import "fmt"
+
+// Pos is a location in a .hi source file. Line and Col are 1-based.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+// String formats the position as file:line:col.
+func (p Pos) String() string {
+	file := p.File
+	if file == "" {
+		file = "<input>"
+	}
+	return fmt.Sprintf("%s:%d:%d", file, p.Line, p.Col)
+}
+
+// Span is the source range a node covers, from the first character of its
+// first line to just past the end of its last line.
+type Span struct {
+	From Pos
+	To   Pos
+}
+
+// Pos returns the position of the node's first character.
+func (s Span) Pos() Pos { return s.From }
+
+// End returns the position just past the node's last character.
+func (s Span) End() Pos { return s.To }
+
 // SortKey represents a field and sort direction.
 type SortKey struct {
 	Path string
 	Asc  bool
 }
 
-// Binding defines a variable binding from header.
+// Binding defines a variable binding from header. For a Glob binding,
+// Target is the file pattern.
 type Binding struct {
+	Span
 	Name   string
 	Target string
 	URL    string
 	Lazy   bool
 	Manual bool
+	Glob   bool
+	// Transforms are applied in order to the loaded value.
+	Transforms []Transform
+}
+
+// Transform is one step of a binding's pipeline: `.path` selects a field
+// of the value, `where <expr>` keeps the array items matching Where.
+type Transform struct {
+	Path  string
+	Where Expr
 }
 
-// Section is a simple markdown section.
+// Section is a simple markdown section. A one-line section renders its text
+// as a title; a Block section spans several lines and keeps the full
+// markdown output. Classes and Attrs come from the header, as in
+// {about .is-wide tab="About": ...}.
 type Section struct {
-	ID   string
-	Text string
+	Span
+	ID      string
+	Classes []string
+	Attrs   []Attr
+	Text    string
+	Block   bool
+}
+
+// Attr is a section attribute: name="value", or a bare flag like hidden
+// with an empty Value.
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// Attr returns the value of the named attribute and whether it is set.
+func (s Section) Attr(name string) (string, bool) {
+	for _, a := range s.Attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
 }
 
-// Loop represents a for-loop block.
+// Loop represents a for-loop block. Where, if set, filters the items before
+// sorting. Offset and Limit select a window of the sorted items; a zero
+// Limit means no limit.
+//
+// With GroupBy set, the filtered items are grouped by that path and the loop
+// runs once per group, binding Vars to the group's key and its items; Sort,
+// Offset and Limit then apply to the groups.
+//
+// As, if set, names how the items are presented, as in
+// [for app in apps as app_card]: a renderer registered with RegisterRenderer
+// or else a partial in the cards directory. Slot, if set, sends the output
+// to that <!--SLOT:name--> in the layout instead of the page content.
 type Loop struct {
-	Vars   []string
-	Source string
-	Sort   []SortKey
+	Span
+	Vars    []string
+	Source  string
+	As      string
+	Slot    string
+	Where   Expr
+	GroupBy string
+	Sort    []SortKey
+	Limit   int
+	Offset  int
+	Body    []Node
+}
+
+// window returns the items selected by the loop's offset and limit.
+func (l Loop) window(items []interface{}) []interface{} {
+	if l.Offset >= len(items) {
+		return nil
+	}
+	items = items[l.Offset:]
+	if l.Limit > 0 && l.Limit < len(items) {
+		items = items[:l.Limit]
+	}
+	return items
+}
+
+// If renders Then when Cond is truthy and Else otherwise. When formatting,
+// Between holds the comment lines between Then and the [else].
+type If struct {
+	Span
+	Cond    Expr
+	Then    []Node
+	Between []Comment
+	Else    []Node
+}
+
+// Comment is a "#" comment line. Parse drops comments; Format keeps them.
+type Comment struct {
+	Span
+	Text string
+}
+
+// Include is an [include] directive. Parse splices the included nodes in
+// its place; Format keeps the directive itself.
+type Include struct {
+	Span
+	Path string
+}
+
+// Define declares a macro. Each [use] renders Body with Params bound to its
+// arguments.
+type Define struct {
+	Span
+	Name   string
+	Params []string
 	Body   []Node
 }
 
-// Node is a body node.
-type Node interface{}
+// Use renders the named macro with Args, paths or literals, bound to its
+// params: [use card thing].
+type Use struct {
+	Span
+	Name string
+	Args []Expr
+}
+
+// Raw is a line of HTML written out as-is, except that {path} placeholders
+// are replaced with the escaped value.
+type Raw struct {
+	Span
+	Text string
+}
+
+// Node is a body node: a Section, Loop, If, Field, Define, Use or Raw, or,
+// when formatting, a Comment or Include.
+type Node interface {
+	Pos() Pos
+	End() Pos
+}
 
-// Field line in loop body.
+// Field line in loop body, with optional pipe filters applied in order:
+// `thing.date_published | date "Jan 2, 2006"`.
 type Field struct {
-	Path string
+	Span
+	Path    string
+	Filters []Filter
+}
+
+// Filter is one pipe filter on a field line and its literal arguments.
+type Filter struct {
+	Name string
+	Args []interface{}
 }
@@ -0,0 +1,71 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+// A Visitor's Visit method is called for each node encountered by Walk. If
+// the returned visitor w is not nil, Walk visits each of the children of n
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(n Node) (w Visitor)
+}
+
+// Walk traverses a node tree in depth-first order, as go/ast.Walk does.
+func Walk(v Visitor, n Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	switch t := n.(type) {
+	case Loop:
+		walkList(v, t.Body)
+	case If:
+		walkList(v, t.Then)
+		walkList(v, t.Else)
+	case Define:
+		walkList(v, t.Body)
+	}
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, n := range nodes {
+		Walk(v, n)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a node tree in depth-first order, calling f for each
+// node and then f(nil) after its children. If f returns false, the
+// children of that node are skipped.
+func Inspect(n Node, f func(Node) bool) {
+	Walk(inspector(f), n)
+}
+
+// InspectAll calls Inspect on each of nodes in turn, as for the body
+// returned by Parse.
+func InspectAll(nodes []Node, f func(Node) bool) {
+	for _, n := range nodes {
+		Inspect(n, f)
+	}
+}
@@ -0,0 +1,97 @@
+// hithisisme - A simple static site generator in Go
+// Copyright (C) 2025  Eric Hamiter
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sitegen
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const walkSrc = `repos = repos.json << https://example.com/repos
+
+{repos: Repos}
+[for repo in repos: stargazers_count]
+  repo.name
+  [if repo.description]
+    repo.description
+  [else]
+    repo.html_url
+`
+
+func TestInspectPositions(t *testing.T) {
+	bindings, nodes, err := ParseFile("index.hi", strings.NewReader(walkSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bindings[0].Pos().String(); got != "index.hi:1:1" {
+		t.Fatalf("binding pos = %s", got)
+	}
+	var got []string
+	InspectAll(nodes, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		got = append(got, fmt.Sprintf("%T %s-%d:%d", n, n.Pos(), n.End().Line, n.End().Col))
+		return true
+	})
+	want := []string{
+		"sitegen.Section index.hi:3:1-3:15",
+		"sitegen.Loop index.hi:4:1-9:18",
+		"sitegen.Field index.hi:5:3-5:12",
+		"sitegen.If index.hi:6:3-9:18",
+		"sitegen.Field index.hi:7:5-7:21",
+		"sitegen.Field index.hi:9:5-9:18",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	_, nodes, err := Parse(strings.NewReader(walkSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	InspectAll(nodes, func(n Node) bool {
+		if n != nil {
+			count++
+		}
+		_, isLoop := n.(Loop)
+		return !isLoop
+	})
+	if count != 2 {
+		t.Fatalf("expected section and loop only, visited %d nodes", count)
+	}
+}
+
+func TestRenderWarningsHavePositions(t *testing.T) {
+	_, nodes, err := ParseFile("index.hi", strings.NewReader("[for x in missing]\n  x.a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings strings.Builder
+	c := &context{stderr: &warnings}
+	var buf strings.Builder
+	if err := c.renderNodes(nodes, map[string]interface{}{}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := warnings.String(); got != "index.hi:1:1: warning: unknown source missing; skipping loop\n" {
+		t.Fatalf("unexpected warnings %q", got)
+	}
+}